@@ -0,0 +1,162 @@
+package argparser
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ColorMode controls whether help, warnings and errors written by an ArgSet
+// are styled with ANSI escape sequences
+type ColorMode int
+
+const (
+	// ColorAuto styles output if it goes to a terminal. Setting the
+	// environment variable FORCE_COLOR to anything but 0 enables styling
+	// regardless and setting NO_COLOR to any non-empty value disables it.
+	// FORCE_COLOR wins if both are set.
+	ColorAuto ColorMode = iota
+	// ColorAlways styles output even if it does not go to a terminal
+	ColorAlways
+	// ColorNever never styles output
+	ColorNever
+)
+
+// Theme holds the SGR parameters used to style the parts of help, warnings
+// and errors, e.g. "1;31" for bold red. Parts with an empty string are not
+// styled.
+type Theme struct {
+	// Heading styles the section headings and the 'Usage:' prefix of help
+	Heading string
+	// Name styles option names and positional arguments
+	Name string
+	// Metavar styles the placeholders of option values
+	Metavar string
+	// Error styles the 'Error:' prefix written by PrintError
+	Error string
+	// Warning styles the 'Warning:' prefix of deprecation warnings
+	Warning string
+}
+
+// DefaultTheme is used by an ArgSet whose Theme is nil
+var DefaultTheme = Theme{
+	Heading: "1",
+	Name:    "36",
+	Metavar: "33",
+	Error:   "1;31",
+	Warning: "1;33",
+}
+
+// paint returns text wrapped in the escape sequences for sgr, or text as is
+// if sgr is empty
+func paint(sgr, text string) string {
+	if sgr == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + sgr + "m" + text + "\x1b[0m"
+}
+
+// The following methods style text with the respective part of t. They return
+// text as is if t is nil, i.e. styling is disabled.
+
+func (t *Theme) heading(text string) string {
+	if t == nil {
+		return text
+	}
+	return paint(t.Heading, text)
+}
+
+func (t *Theme) name(text string) string {
+	if t == nil {
+		return text
+	}
+	return paint(t.Name, text)
+}
+
+func (t *Theme) metavar(text string) string {
+	if t == nil {
+		return text
+	}
+	return paint(t.Metavar, text)
+}
+
+func (t *Theme) errorPrefix(text string) string {
+	if t == nil {
+		return text
+	}
+	return paint(t.Error, text)
+}
+
+func (t *Theme) warningPrefix(text string) string {
+	if t == nil {
+		return text
+	}
+	return paint(t.Warning, text)
+}
+
+// isTerminal reports whether w is a terminal. Writers other than *os.File can
+// claim to be one by implementing 'IsTerminal() bool'.
+func isTerminal(w io.Writer) bool {
+	if t, ok := w.(interface{ IsTerminal() bool }); ok {
+		return t.IsTerminal()
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// theme returns the theme to style the output of argSet with or nil if it
+// should not be styled
+func (argSet *ArgSet) theme() *Theme {
+	switch argSet.Color {
+	case ColorNever:
+		return nil
+	case ColorAuto:
+		if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" {
+			break
+		}
+		if os.Getenv("NO_COLOR") != "" || !isTerminal(argSet.usageOut) {
+			return nil
+		}
+	}
+	if argSet.Theme != nil {
+		return argSet.Theme
+	}
+	return &DefaultTheme
+}
+
+// helpWidth returns the number of columns help is wrapped to: HelpWidth if
+// set, else the width of the terminal the help goes to, falling back to the
+// COLUMNS environment variable, else 80.
+func (argSet *ArgSet) helpWidth() int {
+	if argSet.HelpWidth > 0 {
+		return argSet.HelpWidth
+	}
+	if !isTerminal(argSet.usageOut) {
+		return defaultHelpWidth
+	}
+	if f, ok := argSet.usageOut.(*os.File); ok {
+		if width := terminalWidth(f); width > 0 {
+			return width
+		}
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultHelpWidth
+}
+
+// PrintError writes err to the output of argSet, prefixed with 'Error:',
+// which is styled if the output is, see ColorMode. Nothing is written if err
+// is nil.
+func (argSet *ArgSet) PrintError(err error) {
+	if err == nil {
+		return
+	}
+	msg := strings.TrimPrefix(err.Error(), "Error: ")
+	argSet.println(argSet.theme().errorPrefix("Error:") + " " + msg)
+}
@@ -0,0 +1,139 @@
+package argparser
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeTTY is a writer which claims to be a terminal
+type fakeTTY struct {
+	strings.Builder
+}
+
+func (*fakeTTY) IsTerminal() bool { return true }
+
+// setEnv sets the environment variable key to value, or unsets it if value
+// is empty, and returns a function restoring its previous state
+func setEnv(key, value string) func() {
+	old, found := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	return func() {
+		if found {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	defer setEnv("NO_COLOR", "")()
+	defer setEnv("FORCE_COLOR", "")()
+
+	newArgSet := func(deprecated bool) *ArgSet {
+		var level string
+		argset := NewArgSet()
+		argset.name = "prog"
+		levelArg := NewOptArg(NewString(&level), "log level")
+		levelArg.SetShort("l")
+		if deprecated {
+			levelArg.Deprecate("")
+		}
+		argset.Add("level", levelArg)
+		return argset
+	}
+	styled := "\x1b[1mUsage:\x1b[0m prog [options]"
+	testData := []struct {
+		tty      bool
+		color    ColorMode
+		noColor  string
+		force    string
+		expected bool
+	}{
+		{false, ColorAuto, "", "", false},
+		{true, ColorAuto, "", "", true},
+		{true, ColorAuto, "1", "", false},
+		{false, ColorAuto, "", "1", true},
+		{false, ColorAuto, "", "0", false},
+		{true, ColorAuto, "1", "1", true},
+		{false, ColorAlways, "1", "", true},
+		{true, ColorNever, "", "1", false},
+	}
+	for _, data := range testData {
+		restoreNoColor := setEnv("NO_COLOR", data.noColor)
+		restoreForce := setEnv("FORCE_COLOR", data.force)
+		var out interface {
+			String() string
+			Write([]byte) (int, error)
+		} = &strings.Builder{}
+		if data.tty {
+			out = &fakeTTY{}
+		}
+		argset := newArgSet(false)
+		argset.SetOutput(out)
+		argset.Color = data.color
+		argset.ParseArgs([]string{"--help"})
+		if strings.Contains(out.String(), styled) != data.expected || !data.expected && strings.Contains(out.String(), "\x1b[") {
+			t.Errorf("testing: help with tty=%v, Color=%v, NO_COLOR=%q, FORCE_COLOR=%q; expected styled: %v; got: %q",
+				data.tty, data.color, data.noColor, data.force, data.expected, out.String())
+		}
+		restoreNoColor()
+		restoreForce()
+	}
+
+	// Test that option names, metavars and headings are styled by the theme
+	out := &fakeTTY{}
+	argset := newArgSet(false)
+	argset.SetOutput(out)
+	argset.Theme = &Theme{Name: "32", Metavar: "4"}
+	argset.ParseArgs([]string{"--help"})
+	if !strings.Contains(out.String(), "\n  \x1b[32m-l\x1b[0m, \x1b[32m--level\x1b[0m \x1b[4mLEVEL\x1b[0m\n") || !strings.Contains(out.String(), "Usage: prog") {
+		t.Errorf("testing: help with custom theme; got: %q", out.String())
+	}
+
+	// Test that the columns of CompactFormatter ignore escape sequences
+	out.Reset()
+	argset.HelpFormatter = CompactFormatter{}
+	argset.ParseArgs([]string{"--help"})
+	if !strings.Contains(out.String(), "\x1b[32m--help\x1b[0m             Show this help message and exit\n  \x1b[32m-l\x1b[0m, \x1b[32m--level\x1b[0m \x1b[4mLEVEL\x1b[0m  log level\n") {
+		t.Errorf("testing: CompactFormatter with theme; got: %q", out.String())
+	}
+
+	// Test PrintError and warnings
+	out.Reset()
+	argset.Theme = nil
+	argset.PrintError(errors.New("Error: something failed"))
+	argset.PrintError(nil)
+	if out.String() != "\x1b[1;31mError:\x1b[0m something failed\n" {
+		t.Errorf("testing: PrintError; expected: styled prefix; got: %q", out.String())
+	}
+	out.Reset()
+	argset = newArgSet(true)
+	argset.SetOutput(out)
+	argset.ParseArgs([]string{"--level", "x"})
+	if out.String() != "\x1b[1;33mWarning:\x1b[0m option '--level' is deprecated\n" {
+		t.Errorf("testing: deprecation warning; expected: styled prefix; got: %q", out.String())
+	}
+}
+
+func TestHelpWidthFromTerminal(t *testing.T) {
+	defer setEnv("COLUMNS", "40")()
+	argset := NewArgSet()
+	if w := argset.helpWidth(); w != defaultHelpWidth {
+		t.Errorf("testing: helpWidth without terminal; expected: %d; got: %d", defaultHelpWidth, w)
+	}
+	argset.SetOutput(&fakeTTY{})
+	if w := argset.helpWidth(); w != 40 {
+		t.Errorf("testing: helpWidth with COLUMNS=40; expected: 40; got: %d", w)
+	}
+	argset.HelpWidth = 100
+	if w := argset.helpWidth(); w != 100 {
+		t.Errorf("testing: helpWidth with HelpWidth set; expected: 100; got: %d", w)
+	}
+}
@@ -0,0 +1,43 @@
+package argparser
+
+import "fmt"
+
+// Source describes where the value of an argument came from
+type Source int
+
+const (
+	// SourceDefault means the argument was not given and has its default value
+	SourceDefault Source = iota
+	// SourceCommandLine means the argument was given in the parsed arguments
+	SourceCommandLine
+	// SourceEnv means the argument was not given but was read from the
+	// environment variable it is bound to
+	SourceEnv
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceCommandLine:
+		return "command line"
+	case SourceEnv:
+		return "environment"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// ParseError is the type of all errors returned by Parse. Arg is the name of
+// the argument which caused the error, if the error is specific to one.
+type ParseError struct {
+	Arg string
+	Err error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+func parseErrorf(arg string, format string, a ...interface{}) error {
+	return &ParseError{Arg: arg, Err: fmt.Errorf(format, a...)}
+}
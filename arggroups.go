@@ -0,0 +1,102 @@
+package argparser
+
+const helpAllArgName string = "help-all"
+
+// Group is a section of the help message listing the arguments which belong
+// to it, see Argument.SetGroup. Groups are listed in the order they were
+// first used, after the arguments which belong to no group.
+type Group struct {
+	name   string
+	argSet *ArgSet
+	// Title is shown as the heading of the section, the group's name if empty
+	Title string
+	// Description is shown below the title
+	Description string
+	// Hidden leaves the group out of the help message shown by --help. It is
+	// still shown by --help-all, which is added when any group is hidden.
+	Hidden bool
+}
+
+// Name returns the name the group was created with
+func (g *Group) Name() string { return g.name }
+
+// Add sets the group of arg to g and adds it to the ArgSet of g
+func (g *Group) Add(name string, arg *Argument) error {
+	if arg != nil {
+		arg.SetGroup(g.name)
+	}
+	return g.argSet.Add(name, arg)
+}
+
+func (g *Group) title() string {
+	if g.Title != "" {
+		return g.Title
+	}
+	return g.name
+}
+
+// Group returns the group with the given name, creating it if it does not
+// exist yet.
+func (argSet *ArgSet) Group(name string) *Group {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	return argSet.group(name)
+}
+
+func (argSet *ArgSet) group(name string) *Group {
+	for _, g := range argSet.groups {
+		if g.name == name {
+			return g
+		}
+	}
+	g := &Group{name: name, argSet: argSet}
+	argSet.groups = append(argSet.groups, g)
+	return g
+}
+
+// Groups returns all groups of argSet in the order they are shown in help
+func (argSet *ArgSet) Groups() []*Group {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	return append([]*Group(nil), argSet.groups...)
+}
+
+// addHelpAll adds the --help-all switch if any group is hidden and no
+// optional argument named 'help-all' exists yet
+func (argSet *ArgSet) addHelpAll() {
+	hidden := false
+	for _, g := range argSet.groups {
+		hidden = hidden || g.Hidden
+	}
+	if !hidden {
+		return
+	}
+	if _, found := argSet.optArgs[argSet.OptArgPrefix+helpAllArgName]; found {
+		return
+	}
+	var helpAll bool
+	helpAllArg := NewSwitchArg(NewBool(&helpAll), "Show help for all arguments, including hidden groups, and exit")
+	helpAllArg.SetAction(func(set *ArgSet, _ interface{}) error {
+		if set.Usage != nil {
+			set.Usage()
+			return ErrStopParsing
+		}
+		set.outMu.Lock()
+		defer set.outMu.Unlock()
+		if err := set.writeUsage(true); err != nil {
+			return err
+		}
+		return ErrStopParsing
+	})
+	argSet.add(helpAllArgName, helpAllArg)
+}
+
+// groupOf returns the group arg belongs to or nil if it belongs to none
+func (argSet *ArgSet) groupOf(arg *Argument) *Group {
+	for _, g := range argSet.groups {
+		if g.name == arg.group {
+			return g
+		}
+	}
+	return nil
+}
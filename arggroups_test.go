@@ -0,0 +1,81 @@
+package argparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	var port, level, debug int
+	var host, file string
+	argset := NewArgSet()
+	argset.name = "prog"
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+
+	network := argset.Group("network")
+	network.Title = "Network"
+	network.Description = "Where to listen"
+	argset.Add("file", NewPosArg(NewString(&file), "input file"))
+	logArg := NewOptArg(NewInt(&level), "log level")
+	logArg.SetGroup("Logging")
+	argset.Add("level", logArg)
+	network.Add("port", NewOptArg(NewInt(&port), "port to listen on"))
+	network.Add("host", NewOptArg(NewString(&host), "host to listen on"))
+	advanced := argset.Group("advanced")
+	advanced.Hidden = true
+	advanced.Add("debug", NewOptArg(NewInt(&debug), "debug level"))
+
+	if groups := argset.Groups(); len(groups) != 3 || groups[0] != network || groups[1].Name() != "Logging" || groups[2] != advanced {
+		t.Errorf("testing: Groups(); expected: network, Logging, advanced; got: %v", groups)
+	}
+
+	if err := argset.ParseArgs([]string{"--help"}); err != nil {
+		t.Fatalf("testing: ParseArgs(--help); expected: no error; got: %v", err)
+	}
+	help := out.String()
+	sections := []string{"Positional Arguments:\n  file", "Optional Arguments:\n  --help", "--help-all", "Network:\n  Where to listen\n\n  --port", "--host", "Logging:\n  --level"}
+	pos := 0
+	for _, section := range sections {
+		i := strings.Index(help[pos:], section)
+		if i < 0 {
+			t.Fatalf("testing: --help; expected: %q after offset %d; got: %q", section, pos, help)
+		}
+		pos += i
+	}
+	if strings.Contains(help, "debug") {
+		t.Errorf("testing: --help; expected: hidden group left out; got: %q", help)
+	}
+
+	out.Reset()
+	if err := argset.ParseArgs([]string{"--help-all"}); err != nil || !strings.Contains(out.String(), "advanced:\n  --debug") {
+		t.Errorf("testing: ParseArgs(--help-all); expected: hidden group shown; got: %v, %q", err, out.String())
+	}
+
+	// Test that arguments in hidden groups are still parsed
+	if err := argset.ParseArgs([]string{"f", "--debug", "2"}); err != nil || debug != 2 {
+		t.Errorf("testing: ParseArgs(--debug 2); expected: 2; got: %d, %v", debug, err)
+	}
+}
+
+func TestGroupsFromTags(t *testing.T) {
+	config := struct {
+		Verbose bool `argparser:"type=switch"`
+		DB      struct {
+			Host string `argparser:""`
+			Port int    `argparser:"group=Advanced"`
+		} `group:"Database"`
+		Debug bool `argparser:"type=switch,group=Advanced"`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom(); expected: no error; got: %v", err)
+	}
+	if argset.Lookup("verbose").Group() != "" || argset.Lookup("db-host").Group() != "Database" || argset.Lookup("db-port").Group() != "Advanced" {
+		t.Errorf("testing: NewArgSetFrom() with groups; got: %q, %q, %q",
+			argset.Lookup("verbose").Group(), argset.Lookup("db-host").Group(), argset.Lookup("db-port").Group())
+	}
+	if groups := argset.Groups(); len(groups) != 2 || groups[0].Name() != "Database" || groups[1].Name() != "Advanced" {
+		t.Errorf("testing: Groups(); expected: Database, Advanced; got: %v", groups)
+	}
+}
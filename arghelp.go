@@ -0,0 +1,255 @@
+package argparser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HelpFormatter writes the help message described by m to w. Set the
+// HelpFormatter field of an ArgSet to change how its help looks without
+// having to reimplement Usage. Besides ClassicFormatter, which is used by
+// default, CompactFormatter, GNUFormatter, JSONFormatter and TemplateFormatter
+// are provided. An error returned by FormatHelp is returned by Parse when help
+// is requested.
+type HelpFormatter interface {
+	FormatHelp(w io.Writer, m *HelpModel) error
+}
+
+// HelpModel describes the help message of an ArgSet
+type HelpModel struct {
+	// Name is the name of the program
+	Name string `json:"name"`
+	// Usage is the synopsis of the command, e.g. 'prog [options] FILE'
+	Usage       string `json:"usage"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"`
+	// Width is the number of columns help should be wrapped to
+	Width int `json:"-"`
+	// Theme styles the help if not nil, i.e. if the output supports it
+	Theme *Theme `json:"-"`
+	// Sections holds the positional and the optional arguments which belong
+	// to no group, always in this order, followed by one section per group
+	// which has arguments to show
+	Sections []HelpSection `json:"sections"`
+}
+
+// HelpSection is a titled list of arguments in a HelpModel
+type HelpSection struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Group is the name of the group the section shows, empty for the
+	// sections of arguments which belong to no group
+	Group string    `json:"group,omitempty"`
+	Args  []HelpArg `json:"arguments"`
+}
+
+// HelpArg describes an argument in a HelpModel
+type HelpArg struct {
+	// Name is the name the argument was added with
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+	// Names lists the names an optional argument is given with on the command
+	// line, its short name first, e.g. '-c', '--colour', '--color'. It is empty
+	// for positional arguments.
+	Names []string `json:"names,omitempty"`
+	// Short is the short name of an optional argument without prefix
+	Short       string `json:"short,omitempty"`
+	Metavar     string `json:"metavar,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	// Synopsis is how the argument is written on the command line, e.g.
+	// '--emp-id ID ID ID'
+	Synopsis    string   `json:"synopsis"`
+	Help        string   `json:"help,omitempty"`
+	Default     string   `json:"default"`
+	Choices     []string `json:"choices,omitempty"`
+	Env         string   `json:"env,omitempty"`
+	Required    bool     `json:"required"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	Deprecation string   `json:"deprecation,omitempty"`
+}
+
+// HelpModel returns the model of the help message of argSet, leaving out
+// hidden arguments, and hidden groups unless all is true. Unlike most methods
+// of ArgSet it does not wait for running parses so that it can be called from
+// Usage.
+func (argSet *ArgSet) HelpModel(all bool) *HelpModel {
+	if !argSet.frozen {
+		argSet.addVersion()
+	}
+	m := &HelpModel{
+		Name:        argSet.name,
+		Usage:       argSet.usageLine(),
+		Description: argSet.Description,
+		Version:     argSet.Version,
+		Width:       argSet.helpWidth(),
+		Theme:       argSet.theme(),
+	}
+	section := func(title string, g *Group, include func(*Argument) bool) HelpSection {
+		s := HelpSection{Title: title, Args: make([]HelpArg, 0)}
+		if g != nil {
+			s.Description, s.Group = g.Description, g.name
+		}
+		for _, arg := range argSet.args {
+			if !arg.hidden && argSet.groupOf(arg) == g && include(arg) {
+				s.Args = append(s.Args, argSet.helpArg(arg))
+			}
+		}
+		return s
+	}
+	m.Sections = append(m.Sections,
+		section("Positional Arguments", nil, func(arg *Argument) bool { return arg.positional }),
+		section("Optional Arguments", nil, func(arg *Argument) bool { return !arg.positional }))
+	for _, g := range argSet.groups {
+		if g.Hidden && !all {
+			continue
+		}
+		if s := section(g.title(), g, func(*Argument) bool { return true }); len(s.Args) > 0 {
+			m.Sections = append(m.Sections, s)
+		}
+	}
+	return m
+}
+
+// helpArg returns the description of arg in a HelpModel
+func (argSet *ArgSet) helpArg(arg *Argument) HelpArg {
+	msg, deprecated := arg.Deprecation()
+	a := HelpArg{
+		Name:        arg.name,
+		Short:       arg.short,
+		Kind:        arg.Kind(),
+		Metavar:     arg.Metavar(),
+		Placeholder: arg.Placeholder(),
+		Synopsis:    argSet.synopsis(argSet.OptArgPrefix+arg.name, arg),
+		Help:        arg.help,
+		Default:     arg.DefaultString(),
+		Choices:     arg.Choices(),
+		Env:         arg.env,
+		Required:    arg.Required(),
+		Deprecated:  deprecated,
+		Deprecation: msg,
+	}
+	if !arg.positional {
+		if arg.short != "" {
+			a.Names = append(a.Names, shortOptPrefix+arg.short)
+		}
+		a.Names = append(a.Names, argSet.OptArgPrefix+arg.name)
+		for _, alias := range arg.aliases {
+			a.Names = append(a.Names, argSet.OptArgPrefix+alias)
+		}
+	}
+	return a
+}
+
+// usageLine returns the synopsis of the command shown at the top of help. It
+// lists the required optional arguments and the positional arguments, all
+// others are summarized as '[options]'.
+func (argSet *ArgSet) usageLine() string {
+	parts := []string{argSet.name}
+	if len(argSet.optArgs) > 0 {
+		parts = append(parts, "[options]")
+	}
+	for _, arg := range argSet.args {
+		if arg.required && !arg.positional && !arg.hidden {
+			parts = append(parts, argSet.synopsis(argSet.OptArgPrefix+arg.name, arg))
+		}
+	}
+	for _, pos := range argSet.posArgs {
+		if !pos.arg.hidden {
+			parts = append(parts, pos.arg.Metavar())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (argSet *ArgSet) defaultUsage() error {
+	return argSet.writeUsage(false)
+}
+
+// writeUsage writes the help message using the HelpFormatter of argSet and
+// returns the error of the formatter, if any. Hidden groups are only shown if
+// all is true.
+func (argSet *ArgSet) writeUsage(all bool) error {
+	f := argSet.HelpFormatter
+	if f == nil {
+		f = ClassicFormatter{}
+	}
+	if err := f.FormatHelp(argSet.usageOut, argSet.HelpModel(all)); err != nil {
+		return fmt.Errorf("cannot write help: %s", err)
+	}
+	return nil
+}
+
+// ClassicFormatter is the default HelpFormatter. It lists every argument on
+// its own line followed by its help, indented by a tab, and notes about its
+// default value, choices, environment variable etc.
+type ClassicFormatter struct{}
+
+func (ClassicFormatter) FormatHelp(w io.Writer, m *HelpModel) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s\n\n", m.Theme.heading("Usage:"), m.Usage)
+	b.WriteString(strings.Join(wrapText(m.Description, m.Width), "\n"))
+	for _, s := range m.Sections {
+		fmt.Fprintf(b, "\n\n%s", m.Theme.heading(s.Title+":"))
+		if s.Description != "" {
+			fmt.Fprintf(b, "\n  %s\n", strings.Join(wrapText(s.Description, m.Width-2), "\n  "))
+		}
+		for _, a := range s.Args {
+			fmt.Fprintf(b, "\n  %s\n\t%s", argLabel(a, m.Theme), classicHelpText(a, m.Width))
+		}
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// argLabel returns the names of a followed by its placeholder, styled with t
+func argLabel(a HelpArg, t *Theme) string {
+	if a.Kind == KindPositional {
+		return t.name(a.Synopsis)
+	}
+	names := make([]string, len(a.Names))
+	for i, name := range a.Names {
+		names[i] = t.name(name)
+	}
+	label := strings.Join(names, ", ")
+	if a.Placeholder != "" {
+		label += " " + t.metavar(a.Placeholder)
+	}
+	return label
+}
+
+// classicHelpText returns the help of a wrapped to fit after a tab, followed
+// by notes
+func classicHelpText(a HelpArg, width int) string {
+	b := &strings.Builder{}
+	for i, line := range wrapText(a.Help, width-8) {
+		if i > 0 {
+			b.WriteString("\n")
+			if line != "" {
+				b.WriteString("\t")
+			}
+		}
+		b.WriteString(line)
+	}
+	if a.Choices != nil && a.Kind != KindSwitch {
+		fmt.Fprintf(b, "  (Choices: %s)", strings.Join(a.Choices, ", "))
+	}
+	if a.Kind != KindSwitch {
+		fmt.Fprintf(b, "  (Default: %s)", a.Default)
+	}
+	if a.Required && a.Kind != KindPositional {
+		b.WriteString("  (Required)")
+	}
+	if a.Env != "" {
+		fmt.Fprintf(b, "  (Env: %s)", a.Env)
+	}
+	if a.Deprecated && a.Kind != KindPositional {
+		b.WriteString("  (Deprecated")
+		if a.Deprecation != "" {
+			b.WriteString(": " + a.Deprecation)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package argparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// writeColumn writes label, which is labelWidth characters wide without
+// escape sequences, indented by indent spaces followed by text wrapped to start
+// at column col. text starts on the next line if label does not fit before col.
+func writeColumn(b *strings.Builder, indent int, label string, labelWidth int, text string, col, width int) {
+	line := strings.Repeat(" ", indent) + label
+	n := indent + labelWidth
+	lines := wrapText(text, width-col)
+	if len(lines) == 0 || lines[0] == "" && len(lines) == 1 {
+		b.WriteString(line + "\n")
+		return
+	}
+	if n+2 <= col {
+		line += strings.Repeat(" ", col-n)
+	} else {
+		b.WriteString(line + "\n")
+		line = strings.Repeat(" ", col)
+	}
+	for i, l := range lines {
+		if i > 0 {
+			line = ""
+			if l != "" {
+				line = strings.Repeat(" ", col)
+			}
+		}
+		b.WriteString(line + l + "\n")
+	}
+}
+
+// CompactFormatter is a HelpFormatter listing every argument on a single
+// line with its help aligned in a second column, like Python's argparse.
+type CompactFormatter struct{}
+
+func (CompactFormatter) FormatHelp(w io.Writer, m *HelpModel) error {
+	// help starts after the longest label but at most at maxCol, labels which
+	// do not fit are followed by their help on the next line
+	const maxCol = 30
+	col := 0
+	for _, s := range m.Sections {
+		for _, a := range s.Args {
+			if n := utf8.RuneCountInString(argLabel(a, nil)) + 4; n > col {
+				col = n
+			}
+		}
+	}
+	if col > maxCol {
+		col = maxCol
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s\n", m.Theme.heading("Usage:"), m.Usage)
+	if m.Description != "" {
+		fmt.Fprintf(b, "\n%s\n", strings.Join(wrapText(m.Description, m.Width), "\n"))
+	}
+	for _, s := range m.Sections {
+		if len(s.Args) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n%s\n", m.Theme.heading(s.Title+":"))
+		if s.Description != "" {
+			fmt.Fprintf(b, "  %s\n", strings.Join(wrapText(s.Description, m.Width-2), "\n  "))
+		}
+		for _, a := range s.Args {
+			text := a.Help
+			if a.Kind != KindSwitch && a.Default != "" {
+				text += fmt.Sprintf(" (default: %s)", a.Default)
+			}
+			if a.Required && a.Kind != KindPositional {
+				text += " (required)"
+			}
+			label, plain := argLabel(a, m.Theme), argLabel(a, nil)
+			writeColumn(b, 2, label, utf8.RuneCountInString(plain), strings.TrimSpace(text), col, m.Width)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// GNUFormatter is a HelpFormatter following the style of GNU programs: long
+// options are aligned whether or not they have a short name, a single value
+// is attached with '=' and help starts at column 30.
+type GNUFormatter struct{}
+
+func (GNUFormatter) FormatHelp(w io.Writer, m *HelpModel) error {
+	const col = 30
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s\n", m.Theme.heading("Usage:"), strings.Replace(m.Usage, " [options]", " [OPTION]...", 1))
+	if m.Description != "" {
+		fmt.Fprintf(b, "%s\n", strings.Join(wrapText(m.Description, m.Width), "\n"))
+	}
+	mandatory := false
+	for _, s := range m.Sections {
+		for _, a := range s.Args {
+			mandatory = mandatory || a.Kind == KindOptional && a.Short != ""
+		}
+	}
+	if mandatory {
+		b.WriteString("\nMandatory arguments to long options are mandatory for short options too.\n")
+	}
+
+	// arguments which belong to no group are listed together without a title
+	ungrouped := false
+	for _, s := range m.Sections {
+		if len(s.Args) == 0 {
+			continue
+		}
+		if s.Group != "" {
+			fmt.Fprintf(b, "\n%s\n", m.Theme.heading(s.Title+":"))
+			if s.Description != "" {
+				fmt.Fprintf(b, "%s\n", strings.Join(wrapText(s.Description, m.Width), "\n"))
+			}
+		} else if !ungrouped {
+			b.WriteString("\n")
+			ungrouped = true
+		}
+		for _, a := range s.Args {
+			text := a.Help
+			if a.Required && a.Kind != KindPositional {
+				text += " (required)"
+			}
+			label, plain := gnuLabel(a, m.Theme), gnuLabel(a, nil)
+			writeColumn(b, 2, label, utf8.RuneCountInString(plain), strings.TrimSpace(text), col, m.Width)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// gnuLabel returns the names of a as shown by GNUFormatter, styled with t
+func gnuLabel(a HelpArg, t *Theme) string {
+	if a.Kind == KindPositional {
+		return t.name(a.Synopsis)
+	}
+	names := make([]string, len(a.Names))
+	for i, name := range a.Names {
+		names[i] = t.name(name)
+	}
+	label := "    "
+	if a.Short != "" {
+		label = names[0] + ", "
+		names = names[1:]
+	}
+	label += strings.Join(names, ", ")
+	switch {
+	case a.Placeholder == "":
+	case a.Placeholder == a.Metavar:
+		label += "=" + t.metavar(a.Placeholder)
+	default:
+		label += " " + t.metavar(a.Placeholder)
+	}
+	return label
+}
+
+// JSONFormatter is a HelpFormatter writing the HelpModel as JSON, e.g. for
+// generating documentation or completion scripts
+type JSONFormatter struct {
+	// Indent is used to indent nested elements, the output is compact if empty
+	Indent string
+}
+
+func (f JSONFormatter) FormatHelp(w io.Writer, m *HelpModel) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", f.Indent)
+	return enc.Encode(m)
+}
+
+// TemplateFormatter is a HelpFormatter executing a text/template with the
+// HelpModel as data
+type TemplateFormatter struct {
+	Template *template.Template
+}
+
+// NewTemplateFormatter returns a TemplateFormatter for the template text.
+// Besides the built-in functions of text/template the template can call
+//
+//	wrap WIDTH TEXT    wrap TEXT to lines of at most WIDTH characters
+//	indent N TEXT      indent every non empty line of TEXT by N spaces
+//	join SEP LIST      join the strings of LIST with SEP
+//	pad WIDTH TEXT     append spaces to TEXT up to WIDTH characters
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	t, err := template.New("help").Funcs(template.FuncMap{
+		"wrap": func(width int, text string) string {
+			return strings.Join(wrapText(text, width), "\n")
+		},
+		"indent": func(n int, text string) string {
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				if line != "" {
+					lines[i] = strings.Repeat(" ", n) + line
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+		"pad": func(width int, text string) string {
+			if n := utf8.RuneCountInString(text); n < width {
+				return text + strings.Repeat(" ", width-n)
+			}
+			return text
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateFormatter{Template: t}, nil
+}
+
+func (f *TemplateFormatter) FormatHelp(w io.Writer, m *HelpModel) error {
+	return f.Template.Execute(w, m)
+}
@@ -0,0 +1,167 @@
+package argparser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func newHelpTestArgSet() *ArgSet {
+	var ids []int
+	var colour, host, path string
+	var verbose bool
+	argset := NewArgSet()
+	argset.name = "prog"
+	argset.Description = "Does things to files."
+	ida := NewOptArg(NewIntList(&ids), "ids of the employees to process")
+	ida.SetNArgs(3)
+	ida.SetMetavar("ID")
+	argset.Add("emp-id", ida)
+	colourArg := NewOptArg(NewString(&colour), "colour of the output")
+	colourArg.SetShort("c")
+	colourArg.SetChoices("red", "blue")
+	argset.Add("colour", colourArg)
+	argset.Add("verbose", NewSwitchArg(NewBool(&verbose), "be verbose"))
+	argset.Add("file", NewPosArg(NewPath(&path, 0), "file to process"))
+	net := argset.Group("net")
+	net.Title = "Network"
+	net.Add("host", NewOptArg(NewString(&host), "host to connect to"))
+	return argset
+}
+
+func TestHelpModel(t *testing.T) {
+	argset := newHelpTestArgSet()
+	m := argset.HelpModel(false)
+	if m.Name != "prog" || m.Usage != "prog [options] PATH" || m.Description != "Does things to files." {
+		t.Errorf("testing: HelpModel metadata; got: %q, %q, %q", m.Name, m.Usage, m.Description)
+	}
+	titles := []string{}
+	for _, s := range m.Sections {
+		titles = append(titles, s.Title)
+	}
+	if strings.Join(titles, ",") != "Positional Arguments,Optional Arguments,Network" {
+		t.Errorf("testing: HelpModel sections; expected: Positional Arguments,Optional Arguments,Network; got: %v", titles)
+	}
+	colour := m.Sections[1].Args[2]
+	if colour.Name != "colour" || colour.Kind != KindOptional || strings.Join(colour.Names, ",") != "-c,--colour" ||
+		colour.Synopsis != "--colour {red,blue}" || len(colour.Choices) != 2 {
+		t.Errorf("testing: HelpModel argument; got: %+v", colour)
+	}
+}
+
+func TestHelpFormatters(t *testing.T) {
+	tmpl, err := NewTemplateFormatter("{{.Usage}}\n{{range .Sections}}{{range .Args}}{{pad 16 .Synopsis}}{{.Help}}\n{{end}}{{end}}")
+	if err != nil {
+		t.Fatalf("testing: NewTemplateFormatter; expected: no error; got: %v", err)
+	}
+	testData := []struct {
+		formatter HelpFormatter
+		expected  []string
+	}{
+		{nil, []string{"Usage: prog [options] PATH\n\nDoes things to files.\n\nPositional Arguments:\n  PATH\n\tfile to process", "\n  --emp-id ID ID ID\n"}},
+		{ClassicFormatter{}, []string{"\n  -c, --colour {red,blue}\n\tcolour of the output  (Choices: red, blue)  (Default: )\n"}},
+		{CompactFormatter{}, []string{"Usage: prog [options] PATH\n", "\n  PATH                     file to process\n", "\n  --emp-id ID ID ID        ids of the employees to process (default: [])\n", "\nNetwork:\n  --host HOST              host to connect to\n"}},
+		{GNUFormatter{}, []string{"Usage: prog [OPTION]... PATH\n", "\n  -c, --colour={red,blue}     colour of the output\n", "\n      --emp-id ID ID ID       ids", "\n      --verbose               be verbose\n"}},
+		{tmpl, []string{"prog [options] PATH\nPATH            file to process\n--help          Show"}},
+	}
+	for _, data := range testData {
+		argset := newHelpTestArgSet()
+		argset.HelpFormatter = data.formatter
+		out := &strings.Builder{}
+		argset.SetOutput(out)
+		if err := argset.ParseArgs([]string{"--help"}); err != nil {
+			t.Errorf("testing: --help with %T; expected: no error; got: %v", data.formatter, err)
+		}
+		for _, expected := range data.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("testing: --help with %T; expected: %q; got: %q", data.formatter, expected, out.String())
+			}
+		}
+	}
+
+	// Test that the JSON output decodes to the model
+	argset := newHelpTestArgSet()
+	argset.HelpFormatter = JSONFormatter{Indent: "  "}
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.ParseArgs([]string{"--help"})
+	var decoded struct {
+		Usage    string
+		Sections []struct {
+			Title string
+			Args  []struct {
+				Name string
+				Kind string
+			} `json:"arguments"`
+		}
+	}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("testing: JSONFormatter; expected: valid JSON; got: %v", err)
+	}
+	if decoded.Usage != "prog [options] PATH" || len(decoded.Sections) != 3 || decoded.Sections[0].Args[0].Kind != "positional" {
+		t.Errorf("testing: JSONFormatter; got: %+v", decoded)
+	}
+
+	// Test that Usage takes precedence over HelpFormatter
+	called := false
+	argset.Usage = func() { called = true }
+	out.Reset()
+	argset.ParseArgs([]string{"--help"})
+	if !called || out.Len() != 0 {
+		t.Errorf("testing: Usage with HelpFormatter; expected: only Usage called; got: %q", out.String())
+	}
+
+	// Test that CompactFormatter starts help at the maximum column if a label does not fit
+	var id string
+	argset = NewArgSet()
+	argset.name = "prog"
+	argset.Add("identifier-of-the-employee", NewOptArg(NewString(&id), "employee id"))
+	argset.HelpFormatter = CompactFormatter{}
+	out.Reset()
+	argset.SetOutput(out)
+	argset.ParseArgs([]string{"--help"})
+	if expected := "\n  --identifier-of-the-employee IDENTIFIER_OF_THE_EMPLOYEE\n" + strings.Repeat(" ", 30) + "employee id\n"; !strings.Contains(out.String(), expected) {
+		t.Errorf("testing: CompactFormatter with long labels only; expected: %q; got: %q", expected, out.String())
+	}
+
+	// Test that errors of the formatter are returned by Parse
+	failing := &TemplateFormatter{Template: template.Must(template.New("help").Parse("{{.Nope}}"))}
+	argset = newHelpTestArgSet()
+	argset.HelpFormatter = failing
+	argset.SetOutput(&strings.Builder{})
+	if err := argset.ParseArgs([]string{"--help"}); err == nil || !strings.Contains(err.Error(), "Nope") {
+		t.Errorf("testing: --help with failing template; expected: error; got: %v", err)
+	}
+}
+
+func TestHelpModelDefaults(t *testing.T) {
+	config := struct {
+		Size ByteSize `argparser:"default=64MiB"`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Test that help shows the default before the first parse and keeps
+	// showing it once another value has been parsed
+	for _, args := range [][]string{nil, {"--size", "1KiB"}} {
+		if args != nil {
+			if err := argset.ParseArgs(args); err != nil {
+				t.Fatalf("testing: ParseArgs(%q); expected: no error; got: %v", args, err)
+			}
+		}
+		var size HelpArg
+		for _, s := range argset.HelpModel(false).Sections {
+			for _, a := range s.Args {
+				if a.Name == "size" {
+					size = a
+				}
+			}
+		}
+		if size.Default != "64MiB" {
+			t.Errorf("testing: HelpModel after ParseArgs(%q); expected: default 64MiB; got: %+v", args, size)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package argparser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	responseFilePrefix   string = "@"
+	maxResponseFileDepth int    = 10
+)
+
+// responseToken is an argument read from a response file along with the line
+// it started on
+type responseToken struct {
+	value  string
+	line   int
+	quoted bool // token started with a quote hence is never expanded
+}
+
+// tokenizeResponseFile splits the contents of a response file into arguments
+// using shell-like rules: arguments are separated by white space, single quotes
+// preserve everything up to the next single quote, double quotes do the same
+// except that '\"' and '\\' are unescaped, a '\' outside quotes escapes the
+// next character and '#' at the start of an argument comments out the rest of
+// the line.
+func tokenizeResponseFile(content string) ([]responseToken, error) {
+	tokens := make([]responseToken, 0)
+	b := &strings.Builder{}
+	runes := []rune(content)
+	line := 1
+	var cur *responseToken
+
+	startToken := func(quoted bool) {
+		if cur == nil {
+			cur = &responseToken{line: line, quoted: quoted}
+		}
+	}
+	endToken := func() {
+		if cur != nil {
+			cur.value = b.String()
+			tokens = append(tokens, *cur)
+			b.Reset()
+			cur = nil
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			endToken()
+			line++
+		case r == ' ' || r == '\t' || r == '\r':
+			endToken()
+		case r == '#' && cur == nil:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("%d: trailing backslash", line)
+			}
+			i++
+			if runes[i] == '\n' { // line continuation
+				line++
+				continue
+			}
+			startToken(false)
+			b.WriteRune(runes[i])
+		case r == '\'' || r == '"':
+			startToken(true)
+			quoteLine := line
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					closed = true
+					break
+				}
+				if runes[i] == '\n' {
+					line++
+				}
+				if r == '"' && runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("%d: unterminated %c quote", quoteLine, r)
+			}
+		default:
+			startToken(false)
+			b.WriteRune(r)
+		}
+	}
+	endToken()
+	return tokens, nil
+}
+
+// expandResponseFiles replaces every argument of the form '@file' with the
+// arguments read from file. Response files can include other response files,
+// relative paths being resolved against the directory of the including file.
+func expandResponseFiles(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, responseFilePrefix) || len(arg) == len(responseFilePrefix) {
+			expanded = append(expanded, arg)
+			continue
+		}
+		fileArgs, err := readResponseFile(strings.TrimPrefix(arg, responseFilePrefix), nil)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, fileArgs...)
+	}
+	return expanded, nil
+}
+
+// readResponseFile returns the arguments read from path, expanding nested
+// response files. includedBy holds the absolute paths of the files which
+// included path and is used to detect cycles.
+func readResponseFile(path string, includedBy []string) ([]string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, pathError(path, err)
+	}
+	for _, p := range includedBy {
+		if p == absPath {
+			return nil, fmt.Errorf("%s: response file includes itself", path)
+		}
+	}
+	if len(includedBy) >= maxResponseFileDepth {
+		return nil, fmt.Errorf("%s: response files nested more than %d levels deep", path, maxResponseFileDepth)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, pathError(path, err)
+	}
+	tokens, err := tokenizeResponseFile(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", path, err)
+	}
+
+	args := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.quoted || !strings.HasPrefix(tok.value, responseFilePrefix) || len(tok.value) == len(responseFilePrefix) {
+			args = append(args, tok.value)
+			continue
+		}
+		nested := strings.TrimPrefix(tok.value, responseFilePrefix)
+		if !filepath.IsAbs(nested) {
+			nested = filepath.Join(filepath.Dir(path), nested)
+		}
+		nestedArgs, err := readResponseFile(nested, append(includedBy, absPath))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, tok.line, err)
+		}
+		args = append(args, nestedArgs...)
+	}
+	return args, nil
+}
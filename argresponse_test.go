@@ -0,0 +1,129 @@
+package argparser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeResponseFile(t *testing.T) {
+	data := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"a b\tc\nd\r\n", []string{"a", "b", "c", "d"}},
+		{"# comment\n--opt 1 # trailing comment\n", []string{"--opt", "1"}},
+		{"a#b", []string{"a#b"}},
+		{`'a b' "c d" 'x"y' "x'y"`, []string{"a b", "c d", `x"y`, "x'y"}},
+		{`"a \" \\ \n" '\'`, []string{`a " \ \n`, `\`}},
+		{`a\ b c\\d`, []string{"a b", `c\d`}},
+		{"a\\\nb", []string{"ab"}},
+		{`'' ""`, []string{"", ""}},
+		{`pre'fix'"ed"`, []string{"prefixed"}},
+	}
+	for _, val := range data {
+		tokens, err := tokenizeResponseFile(val.input)
+		if err != nil {
+			t.Errorf("testing: tokenizeResponseFile(%q); expected: no error; got: %s", val.input, err)
+			continue
+		}
+		got := make([]string, len(tokens))
+		for i, tok := range tokens {
+			got[i] = tok.value
+		}
+		if !reflect.DeepEqual(val.expected, got) {
+			t.Errorf("testing: tokenizeResponseFile(%q); expected: %q; got: %q", val.input, val.expected, got)
+		}
+	}
+
+	for input, line := range map[string]string{"a\n'b": "2:", "a\nb\n\"c\nd": "3:", "a\\": "1:"} {
+		if _, err := tokenizeResponseFile(input); err == nil || !strings.HasPrefix(err.Error(), line) {
+			t.Errorf("testing: tokenizeResponseFile(%q); expected: error at line %s; got: %v", input, line, err)
+		}
+	}
+}
+
+func TestResponseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := write("main.txt", "--name 'John Doe'\n# nested file relative to main.txt\n@nested.txt\n'@literal'\n")
+	write("nested.txt", "--ids 1 2\n")
+	empty := write("empty.txt", "'' --ids 3 4\n")
+	cycle := write("cycle.txt", "--a\n@cycle.txt\n")
+	bad := write("bad.txt", "--a\n\n'unterminated\n")
+	missing := write("missing.txt", "--a\n@does-not-exist.txt\n")
+
+	var name, label string
+	var ids []int
+	argset := NewArgSet()
+	argset.Add("name", NewOptArg(NewString(&name), ""))
+	idsArg := NewOptArg(NewIntList(&ids), "")
+	idsArg.SetNArgs(2)
+	argset.Add("ids", idsArg)
+	argset.Add("label", NewPosArg(NewString(&label), ""))
+
+	// Test that response files are not expanded unless enabled
+	argset.ArgList = []string{"@" + main}
+	if err := argset.Parse(); err != nil || label != "@"+main {
+		t.Errorf("testing: Parse(%q) without ResponseFiles; expected: label==%q; got: %q, %v", argset.ArgList, "@"+main, label, err)
+	}
+
+	argset.ResponseFiles = true
+	if err := argset.Parse(); err != nil {
+		t.Fatalf("testing: Parse(%q); expected: no error; got: %s", argset.ArgList, err)
+	}
+	if name != "John Doe" || !reflect.DeepEqual(ids, []int{1, 2}) || label != "@literal" {
+		t.Errorf("testing: Parse(%q); expected: name==John Doe, ids==[1 2], label==@literal; got: %q, %v, %q", argset.ArgList, name, ids, label)
+	}
+
+	// Test that an empty token is a value rather than the end of the arguments
+	argset.ArgList = []string{"@" + empty}
+	if err := argset.Parse(); err != nil || label != "" || !reflect.DeepEqual(ids, []int{3, 4}) {
+		t.Errorf("testing: Parse(%q); expected: label==\"\", ids==[3 4]; got: %q, %v, %v", argset.ArgList, label, ids, err)
+	}
+
+	errors := map[string]string{
+		cycle:                              "cycle.txt:2: ",
+		bad:                                "bad.txt:3: unterminated",
+		missing:                            "missing.txt:2: ",
+		filepath.Join(dir, "no-such-file"): "no-such-file: ",
+	}
+	for file, expected := range errors {
+		argset.ArgList = []string{"@" + file}
+		err := argset.Parse()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("testing: Parse(%q); expected: error containing %q; got: %v", argset.ArgList, expected, err)
+		}
+	}
+}
+
+func TestResponseFileDepthLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i := 0; i <= maxResponseFileDepth; i++ {
+		content := "@" + string(rune('a'+i+1))
+		if err := ioutil.WriteFile(filepath.Join(dir, string(rune('a'+i))), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := expandResponseFiles([]string{"@" + filepath.Join(dir, "a")}); err == nil || !strings.Contains(err.Error(), "levels deep") {
+		t.Errorf("testing: expandResponseFiles() with deeply nested files; expected: depth error; got: %v", err)
+	}
+}
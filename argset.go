@@ -1,11 +1,14 @@
 package argparser
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 )
 
 const (
@@ -15,6 +18,8 @@ const (
 	stateNoArgsLeft
 	defaultOptArgPrefix string = "--"
 	packageTag          string = "argparser"
+	helpArgName         string = "help"
+	shortOptPrefix      string = "-"
 )
 
 type posArgWithName struct {
@@ -29,10 +34,43 @@ type ArgSet struct {
 	OptArgPrefix string
 	posArgs      []posArgWithName
 	optArgs      map[string]*Argument
+	args         []*Argument // all arguments in the order they were added
+	groups       []*Group    // all groups in the order they were created
 	usageOut     io.Writer
 	Usage        func()
+	closeHooks   []func() error
+	validators   []func(*ArgSet) error
+	sources      map[*Argument]Source
+	mu           *sync.Mutex // guards the arguments, their values and sources
+	parseMu      *sync.Mutex // serializes parses writing to destination variables
+	holdsParseMu bool        // the parse running on this view holds parseMu
+	outMu        *sync.Mutex // serializes writes to usageOut by concurrent parses
+	frozen       bool        // arguments cannot be added once parsing has started
+	// Version, if not empty, is printed by a --version switch. The switch
+	// shows up in Lookup, Arguments and help as soon as Version is set and
+	// gives way to an optional argument named 'version' added by the user.
+	Version string
+	// VersionBuildInfo adds the module version, VCS revision and Go version
+	// of the running binary to the output of --version
+	VersionBuildInfo bool
+	// ResponseFiles enables replacing every argument of the form '@file' with
+	// the arguments read from file before parsing
+	ResponseFiles bool
+	// StopAtNonOption makes ParseKnown stop parsing options at the first
+	// argument which is not an option, like getopt does when the option string
+	// starts with '+', see ParseKnown
+	StopAtNonOption bool
+	// HelpWidth is the number of columns help is wrapped to. If not set the
+	// width of the terminal is used, or 80 if help does not go to one.
+	HelpWidth int
+	// HelpFormatter writes the help message unless Usage is set,
+	// ClassicFormatter if not set
+	HelpFormatter HelpFormatter
+	// Color controls whether help, warnings and errors are styled
+	Color ColorMode
+	// Theme holds the styles used if Color allows styling, DefaultTheme if nil
+	Theme *Theme
 
-	// mutex
 	// choices
 	//short option and short prefix
 	// only modify source vars if no errors ie make it atomic
@@ -49,7 +87,14 @@ func (argSet *ArgSet) SetOutput(w io.Writer) {
 
 func (argSet *ArgSet) addHelp() {
 	var help bool
-	argSet.Add("help", NewSwitchArg(NewBool(&help), "Show this help message and exit"))
+	helpArg := NewSwitchArg(NewBool(&help), "Show this help message and exit")
+	helpArg.SetAction(func(set *ArgSet, _ interface{}) error {
+		if err := set.usage(); err != nil {
+			return err
+		}
+		return ErrStopParsing
+	})
+	argSet.add(helpArgName, helpArg)
 }
 
 func NewArgSet() *ArgSet {
@@ -59,6 +104,9 @@ func NewArgSet() *ArgSet {
 		usageOut:     os.Stderr,
 		name:         os.Args[0],
 		ArgList:      os.Args[1:],
+		mu:           new(sync.Mutex),
+		parseMu:      new(sync.Mutex),
+		outMu:        new(sync.Mutex),
 	}
 	argSet.addHelp()
 	return argSet
@@ -81,117 +129,790 @@ func NewArgSetFrom(src interface{}) (*ArgSet, error) {
 		return nil, fmt.Errorf("src must be a pointer to a struct")
 	}
 
-	srcVal := reflect.ValueOf(src).Elem()
-
 	newArgSet := NewArgSet()
+	if err := newArgSet.addFields(reflect.ValueOf(src).Elem(), fieldScope{}, nil); err != nil {
+		return nil, err
+	}
+	return newArgSet, nil
+}
+
+// fieldScope holds what the fields of a nested struct inherit from the
+// fields enclosing it
+type fieldScope struct {
+	path       string // path of the enclosing fields used in errors, e.g. 'DB.'
+	namePrefix string
+	envPrefix  string
+	group      string
+}
+
+// addFields creates arguments from the fields of the struct structVal. Every
+// field tagged with 'argparser' becomes an argument, its name and environment
+// variable prefixed as given by scope, except fields of struct type which
+// cannot be used as a Value. Those, like embedded structs, are searched
+// recursively for tagged fields. Embedded structs are flattened while named
+// ones become a namespace, their lower cased field name or the 'prefix' tag
+// being added to the names of their arguments and the 'envprefix' tag, or else
+// the upper cased prefix, to their environment variables. A 'group' given for
+// a struct field applies to all arguments within it which have none.
+func (argSet *ArgSet) addFields(structVal reflect.Value, scope fieldScope, errs *[]error) error {
+	structTyp := structVal.Type()
 	// iterate over all fields of the struct, parse the value of 'argparser' tag
-	// and create arguments accordingly. Skip any field not tagged with 'argparser'
-	for i := 0; i < srcTyp.NumField(); i++ {
-		fieldType := srcTyp.Field(i)
-		fieldVal := srcVal.Field(i)
-		structTags, tagged := srcTyp.Field(i).Tag.Lookup(packageTag)
-		if !tagged {
+	// and create arguments accordingly. Skip any field not tagged with 'argparser'.
+	// If errs is not nil then errors are collected in it so that all fields get
+	// checked, as done by CheckTags.
+	for i := 0; i < structTyp.NumField(); i++ {
+		if err := argSet.addField(structTyp.Field(i), structVal.Field(i), scope, errs); err != nil {
+			if errs == nil {
+				return err
+			}
+			*errs = append(*errs, err)
+		}
+	}
+	return nil
+}
+
+// addField creates the argument, or the nested arguments, for a single field
+// as described by addFields
+func (argSet *ArgSet) addField(fieldType reflect.StructField, fieldVal reflect.Value, scope fieldScope, errs *[]error) error {
+	fieldName := scope.path + fieldType.Name
+	structTags, tagged := fieldType.Tag.Lookup(packageTag)
+	fieldErr := func(err interface{}) error {
+		return fmt.Errorf("Error while creating argument from field '%s': %s", fieldName, err)
+	}
+
+	var tags map[string]string
+	var offsets map[string]int
+	if tagged {
+		var err error
+		if tags, offsets, err = parseTagOffsets(structTags); err != nil {
+			return tagFieldErr(fieldName, err)
+		}
+	}
+	_, hasPrefix := tags["prefix"]
+	_, hasEnvPrefix := tags["envprefix"]
+	namespace := hasPrefix || hasEnvPrefix
+
+	// some keys can also be given as separate struct tags which, unlike the
+	// 'argparser' tag, need no quoting or escaping
+	for _, key := range separateTagKeys {
+		val, found := fieldType.Tag.Lookup(key)
+		if !found {
 			continue
 		}
+		if _, dup := tags[key]; dup {
+			return &TagError{Field: fieldName, Key: key, Offset: offsets[key], Err: fmt.Errorf("also given as a separate '%s' struct tag", key)}
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = val
+	}
 
+	var argVal Value
+	if tagged && !namespace {
 		if !fieldVal.Addr().CanInterface() {
-			return nil, fmt.Errorf("Error while creating argument from field '%s': %s", fieldType.Name, "unexported struct field")
+			return fieldErr("unexported struct field")
 		}
-		argVal, err := NewValue(fieldVal.Addr().Interface())
-		if err != nil {
-			return nil, fmt.Errorf("Error while creating argument from field '%s': %s", fieldType.Name, err)
+		var err error
+		if argVal, err = NewValue(fieldVal.Addr().Interface()); err != nil && fieldType.Type.Kind() != reflect.Struct {
+			return fieldErr(err)
 		}
+	}
 
+	if argVal != nil {
 		arg, name, err := newArgFromTags(argVal, fieldType.Name, structTags)
 		if err != nil {
-			return nil, fmt.Errorf("Error while creating argument from field '%s': %s", fieldType.Name, err)
+			return tagFieldErr(fieldName, err)
+		}
+		if arg.env != "" {
+			arg.SetEnv(scope.envPrefix + arg.env)
+		}
+		if help, found := tags["help"]; found {
+			arg.help = help
 		}
+		arg.SetMetavar(tags["metavar"])
+		arg.SetGroup(tags["group"])
+		if arg.group == "" {
+			arg.SetGroup(scope.group)
+		}
+		if err := argSet.Add(scope.namePrefix+name, arg); err != nil {
+			return fieldErr(err)
+		}
+		return nil
+	}
 
-		newArgSet.Add(name, arg)
+	if fieldType.Type.Kind() != reflect.Struct {
+		if namespace {
+			key := "prefix"
+			if !hasPrefix {
+				key = "envprefix"
+			}
+			return &TagError{Field: fieldName, Key: key, Offset: offsets[key], Err: fmt.Errorf("can only be used on struct fields")}
+		}
+		return nil
+	}
+	// exported fields of embedded structs are accessible even if the
+	// struct type itself is unexported
+	if !fieldType.Anonymous && !fieldVal.Addr().CanInterface() {
+		if !tagged {
+			return nil
+		}
+		return fieldErr("unexported struct field")
+	}
+	for key := range tags {
+		if key != "prefix" && key != "envprefix" && key != "group" {
+			return &TagError{Field: fieldName, Key: key, Offset: offsets[key], Err: fmt.Errorf("cannot be used on a struct field, only prefix, envprefix and group")}
+		}
+	}
+	nested := fieldScope{path: fieldName + ".", namePrefix: scope.namePrefix, envPrefix: scope.envPrefix, group: scope.group}
+	if prefix, found := tags["prefix"]; found || !fieldType.Anonymous {
+		if !found {
+			prefix = strings.ToLower(fieldType.Name)
+		}
+		if prefix != "" {
+			nested.namePrefix += prefix + "-"
+			nested.envPrefix += envName(prefix) + "_"
+		}
+	}
+	if prefix, found := tags["envprefix"]; found {
+		nested.envPrefix = scope.envPrefix
+		if prefix != "" {
+			nested.envPrefix += prefix + "_"
+		}
+	}
+	if group := tags["group"]; group != "" {
+		nested.group = group
 	}
+	return argSet.addFields(fieldVal, nested, errs)
+}
 
-	return newArgSet, nil
+// tagFieldErr sets the field of err to fieldName if it is a *TagError, which
+// is returned as is, and otherwise wraps err like other field errors
+func tagFieldErr(fieldName string, err error) error {
+	if te, ok := err.(*TagError); ok {
+		te.Field = fieldName
+		return te
+	}
+	return fmt.Errorf("Error while creating argument from field '%s': %s", fieldName, err)
+}
+
+// envName converts an argument name to the conventional form of an
+// environment variable name, e.g. 'log-level' to 'LOG_LEVEL'
+func envName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// reservedArgNames are the names of built-in optional arguments which cannot
+// be replaced
+var reservedArgNames = map[string]bool{helpArgName: true}
+
+var (
+	validArgName   = regexp.MustCompile(`^[[:alnum:]][[:alnum:]_.-]*$`)
+	validShortName = regexp.MustCompile(`^[[:alpha:]]$`)
+)
+
+// Add adds arg to argSet under the given name. It returns error if the
+// definition of arg is invalid or conflicts with an existing argument, or if
+// argSet has already started parsing since its definition is frozen then.
+func (argSet *ArgSet) Add(name string, arg *Argument) error {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	if argSet.frozen {
+		return fmt.Errorf("cannot add argument '%s' after parsing has started", name)
+	}
+	if arg != nil {
+		argSet.removeVersion(append(append([]string{name}, arg.aliases...), arg.DeprecatedAliases()...))
+	}
+	defer argSet.addVersion()
+	if err := argSet.checkArg(name, arg); err != nil {
+		return err
+	}
+	argSet.add(name, arg)
+	return nil
 }
 
-func (argSet *ArgSet) Add(name string, arg *Argument) {
+// checkArg returns error if arg cannot be added to argSet under name
+func (argSet *ArgSet) checkArg(name string, arg *Argument) error {
+	if arg == nil {
+		return fmt.Errorf("argument '%s' cannot be nil", name)
+	}
+	if arg.value == nil {
+		return fmt.Errorf("argument '%s' has no value", name)
+	}
+	if arg.name != "" {
+		return fmt.Errorf("argument '%s' has already been added as '%s'", name, arg.name)
+	}
+	names := append(append([]string{name}, arg.aliases...), arg.DeprecatedAliases()...)
+	if arg.positional && len(names) > 1 {
+		return fmt.Errorf("positional argument '%s' cannot have aliases", name)
+	}
+	for i, n := range names {
+		if n == "" {
+			return fmt.Errorf("argument name cannot be empty")
+		}
+		if !validArgName.MatchString(n) {
+			return fmt.Errorf("invalid argument name '%s': must start with a letter or digit followed by letters, digits, '-', '_' or '.'", n)
+		}
+		if reservedArgNames[n] {
+			return fmt.Errorf("argument name '%s' is reserved", n)
+		}
+		if argSet.lookup(n) != nil {
+			return fmt.Errorf("argument name '%s' is already in use", n)
+		}
+		for _, prev := range names[:i] {
+			if prev == n {
+				return fmt.Errorf("argument name '%s' is given more than once", n)
+			}
+		}
+	}
+	if arg.short != "" {
+		if arg.positional {
+			return fmt.Errorf("positional argument '%s' cannot have a short name", name)
+		}
+		if !validShortName.MatchString(arg.short) {
+			return fmt.Errorf("invalid short name '%s' for argument '%s': must be a single letter", arg.short, name)
+		}
+		if argSet.optArgs[shortOptPrefix+arg.short] != nil {
+			return fmt.Errorf("short name '%s' is already in use", arg.short)
+		}
+	}
+	if (arg.nArgs > 1 || arg.nArgs < 0) && !isListValue(arg.value) {
+		return fmt.Errorf("argument '%s' takes %d values but its value of type %s is not a list", name, arg.nArgs, arg.TypeName())
+	}
+	if arg.isSwitch() && !isBoolValue(arg.value) {
+		return fmt.Errorf("argument '%s' takes no value but its value of type %s is not a boolean", name, arg.TypeName())
+	}
+	return nil
+}
+
+// isBoolValue reports whether v can be set without a value, i.e. whether Get()
+// returns a bool or v, or the flag.Value wrapped by it, is a boolean flag
+func isBoolValue(v Value) bool {
+	if _, ok := v.Get().(bool); ok {
+		return true
+	}
+	if f, ok := v.(*FlagValue); ok {
+		if bf, ok := f.v.(interface{ IsBoolFlag() bool }); ok {
+			return bf.IsBoolFlag()
+		}
+	}
+	bf, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+// isListValue reports whether v holds multiple values, i.e. whether Get()
+// returns a slice, other than a byte slice like net.IP, or a map
+func isListValue(v Value) bool {
+	typ := reflect.TypeOf(v.Get())
+	if typ == nil {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		return typ.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return true
+	}
+	return false
+}
+
+func (argSet *ArgSet) add(name string, arg *Argument) {
 	if arg == nil {
 		return
 	}
+	arg.name = name
+	if arg.group != "" {
+		argSet.group(arg.group)
+	}
+	if arg.value != nil {
+		arg.restore = snapshot(arg.value)
+		arg.defValue = cloneValue(arg.value)
+		if arg.defaults != nil {
+			arg.defValue.Set(arg.defaults...)
+		}
+		arg.defString = arg.defValue.String()
+	}
+	argSet.args = append(argSet.args, arg)
 	if arg.positional {
 		argSet.posArgs = append(argSet.posArgs, posArgWithName{name: name, arg: arg})
 		return
 	}
 	argSet.optArgs[argSet.OptArgPrefix+name] = arg
+	if arg.short != "" {
+		argSet.optArgs[shortOptPrefix+arg.short] = arg
+	}
+	for _, alias := range arg.aliases {
+		argSet.optArgs[argSet.OptArgPrefix+alias] = arg
+	}
+	for _, alias := range arg.deprecatedAliases {
+		argSet.optArgs[argSet.OptArgPrefix+alias.name] = arg
+	}
 }
 
-// usage calls the Usage method for the ArgSet if one is specified,
-// or the appropriate default usage function otherwise.
-func (argSet *ArgSet) usage() {
-	if argSet.Usage == nil {
-		argSet.defaultUsage()
-	} else {
-		argSet.Usage()
+// Name returns the program name shown in the usage message
+func (argSet *ArgSet) Name() string { return argSet.name }
+
+// Arguments returns all arguments of argSet in the order they were added,
+// including built-in ones like help. The arguments are copies describing the
+// arguments of argSet, modifying them has no effect on argSet.
+func (argSet *ArgSet) Arguments() []*Argument {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	if !argSet.frozen {
+		argSet.addVersion()
+	}
+	args := make([]*Argument, len(argSet.args))
+	for i, arg := range argSet.args {
+		args[i] = arg.describe()
+	}
+	return args
+}
+
+// Lookup returns the argument added with the given name, or nil if there is
+// none. Like Arguments it returns a copy, modifying it has no effect on argSet.
+func (argSet *ArgSet) Lookup(name string) *Argument {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	if !argSet.frozen {
+		argSet.addVersion()
+	}
+	arg := argSet.lookup(name)
+	if arg == nil {
+		return nil
 	}
+	return arg.describe()
+}
+
+// AddValidator adds fn to the validators which are called by Parse, in the
+// order they were added, after all arguments have been set. Validators can use
+// Source and IsSet to find out which arguments were given. An error returned
+// by fn is returned by Parse as a *ParseError.
+func (argSet *ArgSet) AddValidator(fn func(*ArgSet) error) {
+	argSet.validators = append(argSet.validators, fn)
 }
 
-func (argSet *ArgSet) defaultUsage() {
-	out := argSet.usageOut
-	fmt.Fprintf(out, "Usage of %s:\n\n", argSet.name)
-	// TODO: show list of opt args in sorted order
-	fmt.Fprint(out, argSet.Description)
-	fmt.Fprint(out, "\n\nPositional Arguments:")
+// lookup returns the argument added with the given name or nil if there is none
+func (argSet *ArgSet) lookup(name string) *Argument {
 	for _, p := range argSet.posArgs {
-		val := p.arg.value.Get()
-		fmt.Fprintf(out, "\n  %[1]s  %[2]T\n\t%[3]s  (Default: %[2]v)", p.name, val, p.arg.help, val)
+		if p.name == name {
+			return p.arg
+		}
+	}
+	return argSet.optArgs[argSet.OptArgPrefix+name]
+}
+
+// Source returns where the value of the argument added with the given name
+// came from during the last call to Parse.
+func (argSet *ArgSet) Source(name string) Source {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	arg := argSet.lookup(name)
+	if arg == nil {
+		return SourceDefault
 	}
+	return argSet.sources[arg]
+}
+
+// IsSet reports whether the argument added with the given name was given
+// during the last call to Parse.
+func (argSet *ArgSet) IsSet(name string) bool {
+	return argSet.Source(name) != SourceDefault
+}
 
-	fmt.Fprint(out, "\n\nOptional Arguments:")
+// Reset restores every argument to its default, i.e. the value it had when it
+// was added or the one given by the default tag, so that the ArgSet can parse
+// again, and forgets which arguments were given. Values implementing
+// io.Closer, like InputFile, are closed first.
+func (argSet *ArgSet) Reset() {
+	argSet.parseMu.Lock()
+	defer argSet.parseMu.Unlock()
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	for _, arg := range argSet.args {
+		if c, ok := arg.value.(io.Closer); ok {
+			c.Close()
+		}
+		if arg.restore != nil {
+			arg.restore()
+		}
+		if arg.defaults != nil {
+			// defaults were checked when arg was created
+			arg.value.Set(arg.defaults...)
+		}
+	}
+	argSet.sources = nil
+}
+
+// Clone returns a copy of argSet which can be modified, e.g. by adding
+// arguments, without affecting argSet. The arguments of the clone are bound
+// to the same destination variables as those of argSet. Unlike argSet, the
+// clone accepts new arguments until it starts parsing.
+func (argSet *ArgSet) Clone() *ArgSet {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	clone := *argSet
+	clone.mu = new(sync.Mutex)
+	clone.parseMu = new(sync.Mutex)
+	clone.holdsParseMu = false
+	clone.frozen = false
+	clones := make(map[*Argument]*Argument)
+	cloneArg := func(arg *Argument) *Argument {
+		if c, found := clones[arg]; found {
+			return c
+		}
+		c := *arg
+		c.validators = append([]Validator(nil), arg.validators...)
+		clones[arg] = &c
+		return &c
+	}
+
+	clone.posArgs = make([]posArgWithName, len(argSet.posArgs))
+	for i, p := range argSet.posArgs {
+		clone.posArgs[i] = posArgWithName{name: p.name, arg: cloneArg(p.arg)}
+	}
+	clone.optArgs = make(map[string]*Argument, len(argSet.optArgs))
 	for name, arg := range argSet.optArgs {
-		if arg.isSwitch() {
-			fmt.Fprintf(out, "\n  %[1]s\n\t%s", name, arg.help)
+		clone.optArgs[name] = cloneArg(arg)
+	}
+	clone.args = make([]*Argument, len(argSet.args))
+	for i, arg := range argSet.args {
+		clone.args[i] = cloneArg(arg)
+	}
+	clone.ArgList = append([]string(nil), argSet.ArgList...)
+	clone.groups = make([]*Group, len(argSet.groups))
+	for i, g := range argSet.groups {
+		c := *g
+		c.argSet = &clone
+		clone.groups[i] = &c
+	}
+	clone.closeHooks = append([]func() error(nil), argSet.closeHooks...)
+	clone.validators = append([]func(*ArgSet) error(nil), argSet.validators...)
+	clone.sources = nil
+	return &clone
+}
+
+// OnClose registers fn to be called by Close.
+func (argSet *ArgSet) OnClose(fn func() error) {
+	argSet.closeHooks = append(argSet.closeHooks, fn)
+}
+
+// Close closes every argument value which implements io.Closer, like InputFile
+// and OutputFile, and then calls the functions registered with OnClose in
+// reverse order. It returns the first error encountered.
+func (argSet *ArgSet) Close() error {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	var firstErr error
+	setErr := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, arg := range argSet.args {
+		if c, ok := arg.value.(io.Closer); ok {
+			setErr(c.Close())
+		}
+	}
+	for i := len(argSet.closeHooks) - 1; i >= 0; i-- {
+		setErr(argSet.closeHooks[i]())
+	}
+	return firstErr
+}
+
+// warnDeprecated writes a warning to the output of argSet if arg is
+// deprecated or was given by a deprecated alias, as name
+func (argSet *ArgSet) warnDeprecated(arg *Argument, name string) {
+	prefix := argSet.theme().warningPrefix("Warning:")
+	withMsg := func(warning, msg string) string {
+		if msg != "" {
+			warning += ": " + msg
+		}
+		return prefix + " " + warning
+	}
+	for _, alias := range arg.deprecatedAliases {
+		if name == argSet.OptArgPrefix+alias.name {
+			warning := fmt.Sprintf("option '%s' is deprecated, use '%s%s' instead", name, argSet.OptArgPrefix, arg.name)
+			argSet.println(withMsg(warning, alias.msg))
+		}
+	}
+	if msg, deprecated := arg.Deprecation(); deprecated {
+		kind := "option"
+		if arg.positional {
+			kind = "argument"
+		}
+		argSet.println(withMsg(fmt.Sprintf("%s '%s' is deprecated", kind, name), msg))
+	}
+}
+
+// setDefaults sets every argument which has default values given by the
+// 'default' struct tag to them
+func (argSet *ArgSet) setDefaults() error {
+	for _, arg := range argSet.args {
+		if arg.defaults == nil {
+			continue
+		}
+		argSet.mu.Lock()
+		err := arg.value.Set(arg.defaults...)
+		argSet.mu.Unlock()
+		if err != nil {
+			name := arg.name
+			if !arg.positional {
+				name = argSet.OptArgPrefix + arg.name
+			}
+			return parseErrorf(name, "error while setting default of option '%s': %s", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromEnv sets every argument which is bound to an environment variable,
+// was not given on the command line and whose variable is set, from the value
+// of its variable. Arguments taking multiple values split it at white space.
+func (argSet *ArgSet) setFromEnv() error {
+	for _, arg := range argSet.args {
+		if arg.env == "" || argSet.sources[arg] != SourceDefault {
+			continue
+		}
+		val, found := os.LookupEnv(arg.env)
+		if !found {
 			continue
 		}
-		val := arg.value.Get()
-		fmt.Fprintf(out, "\n  %[1]s  %[2]T\n\t%[3]s  (Default: %[2]v)", name, val, arg.help)
+		values := []string{val}
+		if arg.nArgs > 1 || arg.nArgs < 0 {
+			values = strings.Fields(val)
+		}
+		if err := argSet.setValidated(arg, func() error { return arg.value.Set(values...) }); err != nil {
+			name := arg.name
+			if !arg.positional {
+				name = argSet.OptArgPrefix + arg.name
+			}
+			return parseErrorf(name, "error while setting option '%s' from environment variable %s: %s", name, arg.env, err)
+		}
+		argSet.sources[arg] = SourceEnv
 	}
+	return nil
+}
+
+// runAction calls the action of arg, if any, after it has been set while
+// parsing. stop is true if parsing must stop, err is nil in case the action
+// returned ErrStopParsing, possibly wrapped.
+func (argSet *ArgSet) runAction(arg *Argument, name string) (stop bool, err error) {
+	if arg.action == nil {
+		return false, nil
+	}
+	err = argSet.unlocked(func() error { return arg.action(argSet, arg.value.Get()) })
+	switch err.(type) {
+	case nil:
+		return false, nil
+	case *ParseError:
+		return true, err
+	}
+	if errors.Is(err, ErrStopParsing) {
+		return true, nil
+	}
+	return true, &ParseError{Arg: name, Err: err}
+}
+
+// isOption reports whether arg is given as an option rather than as the value
+// of a positional argument, i.e. whether it is a defined option or starts with
+// '-' and is not just '-'
+func (argSet *ArgSet) isOption(arg string) bool {
+	if _, found := argSet.optArgs[arg]; found {
+		return true
+	}
+	return strings.HasPrefix(arg, argSet.OptArgPrefix) || strings.HasPrefix(arg, shortOptPrefix) && arg != shortOptPrefix
+}
+
+// usage calls the Usage method for the ArgSet if one is specified,
+// or the appropriate default usage function otherwise. It returns the error
+// of the default usage function, if any.
+func (argSet *ArgSet) usage() error {
+	if argSet.Usage == nil {
+		argSet.outMu.Lock()
+		defer argSet.outMu.Unlock()
+		return argSet.defaultUsage()
+	}
+	argSet.Usage()
+	return nil
+}
 
-	fmt.Fprintln(out, "")
+// println writes a line to the output of argSet, without interleaving it with
+// the output of concurrent parses
+func (argSet *ArgSet) println(line string) {
+	argSet.outMu.Lock()
+	defer argSet.outMu.Unlock()
+	fmt.Fprintln(argSet.usageOut, line)
 }
 
+// synopsis returns how arg is written on the command line when given with
+// name, e.g. '--emp-id ID ID ID'
+func (argSet *ArgSet) synopsis(name string, arg *Argument) string {
+	if arg.positional {
+		return arg.Metavar()
+	}
+	if p := arg.Placeholder(); p != "" {
+		return name + " " + p
+	}
+	return name
+}
+
+// Parse parses ArgList, which defaults to os.Args[1:].
 func (argSet *ArgSet) Parse() error {
-	argsToParse := argSet.ArgList
-	curState := stateInit
-	var curArg string
-	visited := make(map[string]bool)
-	var posIndex, argsIndex int
+	return argSet.ParseArgs(argSet.ArgList)
+}
+
+// ParseArgs is like Parse but parses args instead of ArgList.
+func (argSet *ArgSet) ParseArgs(args []string) error {
+	_, err := argSet.parse(args, false)
+	return err
+}
+
+// ParseKnown is like Parse except that unknown optional arguments and surplus
+// positional arguments are not treated as errors but returned in the order
+// they were given. Since it is not known whether an unknown option takes a
+// value, a value given as a separate argument, e.g. '--color always', is
+// treated as a positional argument; it is kept with its option if given as
+// '--color=always'. If StopAtNonOption is true then options are only parsed up
+// to the first non-option argument, which along with all arguments following
+// it fills the positional arguments, the rest being returned.
+func (argSet *ArgSet) ParseKnown() ([]string, error) {
+	return argSet.ParseKnownArgs(argSet.ArgList)
+}
+
+// ParseKnownArgs is like ParseKnown but parses args instead of ArgList.
+func (argSet *ArgSet) ParseKnownArgs(args []string) ([]string, error) {
+	return argSet.parse(args, true)
+}
+
+// parse sets the values of the arguments from args. Parses are serialized so
+// that destination variables are never written concurrently. The parse itself
+// runs on a view of argSet, which is what actions and validators see, and the
+// sources are committed to argSet once it is done. argSet.mu is only held while
+// values are set and parseMu is released while actions or validators run, see
+// unlocked, so that these can call any method of argSet.
+func (argSet *ArgSet) parse(args []string, known bool) ([]string, error) {
+	argSet.parseMu.Lock()
+	defer argSet.parseMu.Unlock()
+	argSet.mu.Lock()
+	argSet.freeze()
+	view := argSet.view(false)
+	view.holdsParseMu = true
+	argSet.mu.Unlock()
+
+	unknown, err := view.run(args, known)
+	argSet.mu.Lock()
+	argSet.sources = view.sources
+	argSet.mu.Unlock()
+	return unknown, err
+}
+
+// unlocked calls fn, an action or validator, with parseMu released if the parse
+// running on argSet holds it, so that fn may call Parse or Reset itself rather
+// than wait for the parse it is called by to finish.
+func (argSet *ArgSet) unlocked(fn func() error) error {
+	if argSet.holdsParseMu {
+		argSet.parseMu.Unlock()
+		defer argSet.parseMu.Lock()
+	}
+	return fn()
+}
+
+// setValidated calls set, which sets the value of arg, with argSet.mu held and
+// validates the result. The value is restored if validation fails so that a
+// rejected value never remains in the destination variable.
+func (argSet *ArgSet) setValidated(arg *Argument, set func() error) error {
+	argSet.mu.Lock()
+	restore := snapshot(arg.value)
+	err := set()
+	argSet.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := argSet.unlocked(arg.validate); err != nil {
+		argSet.mu.Lock()
+		restore()
+		argSet.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// freeze completes the definition of argSet, after which no arguments can be
+// added by the user. It must be called with argSet.mu held.
+func (argSet *ArgSet) freeze() {
+	// create the groups of arguments whose group was set after they were added
+	for _, arg := range argSet.args {
+		if arg.group != "" {
+			argSet.group(arg.group)
+		}
+	}
+	argSet.addVersion()
+	argSet.addHelpAll()
+	argSet.frozen = true
+}
 
-	getArg := func(i int) string {
-		if i < len(argsToParse) {
-			return argsToParse[i]
+func (argSet *ArgSet) run(args []string, known bool) ([]string, error) {
+	argsToParse := args
+	if argSet.ResponseFiles {
+		expanded, err := expandResponseFiles(argsToParse)
+		if err != nil {
+			return nil, &ParseError{Err: err}
 		}
-		return ""
+		argsToParse = expanded
 	}
+	if err := argSet.setDefaults(); err != nil {
+		return nil, err
+	}
+	unknown := make([]string, 0)
+	curState := stateInit
+	var curArg string
+	visited := make(map[*Argument]bool)
+	stopped := false // see StopAtNonOption
+	argSet.sources = make(map[*Argument]Source)
+	var posIndex, argsIndex int
 
 	for {
 		switch curState {
 		case stateInit:
-			arg := getArg(argsIndex)
-			if arg == "" {
+			if argsIndex >= len(argsToParse) {
+				curState = stateNoArgsLeft
+				break
+			}
+			curArg = argsToParse[argsIndex]
+
+			// once the first non-option has been seen the remaining arguments
+			// only fill the positional arguments not given yet, like getopt
+			// does with '+', and are returned otherwise
+			if known && argSet.StopAtNonOption && !stopped && !argSet.isOption(curArg) {
+				stopped = true
+			}
+			if stopped {
+				if posIndex < len(argSet.posArgs) {
+					curState = statePosArg
+					break
+				}
+				unknown = append(unknown, argsToParse[argsIndex:]...)
 				curState = stateNoArgsLeft
 				break
 			}
-			curArg = arg
 
-			// if curArg starts with the configured prefix then process it as an optional arg
-			if strings.HasPrefix(curArg, argSet.OptArgPrefix) {
-				if _, found := argSet.optArgs[curArg]; found {
-					if visited[curArg] { // if curArg is defined but already processed then return error
-						return fmt.Errorf("option '%s' already given", curArg)
+			// if curArg is a defined optional arg or looks like one, see isOption,
+			// then process it as an optional arg
+			if optArg, found := argSet.optArgs[curArg]; found || argSet.isOption(curArg) {
+				if found {
+					// if curArg is defined but already processed, under any of its names,
+					// then return error unless its value can be given repeatedly
+					if _, repeatable := optArg.value.(RepeatableValue); visited[optArg] && !repeatable {
+						return nil, parseErrorf(curArg, "option '%s' already given", curArg)
 					}
 					curState = stateOptArg
 					break
-				} else { // if curArg is not defined as an opt arg then return error
-					return fmt.Errorf("unknown optional argument: %s", curArg)
+				} else if known { // if curArg is not defined as an opt arg then skip it in known mode
+					unknown = append(unknown, curArg)
+					argsIndex++
+					break
+				} else { // otherwise return error
+					return nil, parseErrorf(curArg, "unknown optional argument: %s", curArg)
 				}
 			}
 
@@ -204,51 +925,93 @@ func (argSet *ArgSet) Parse() error {
 
 			// since all defined positional and optional args have been processed, curArg
 			// is an undefined positional arg
-			return fmt.Errorf("Unknown positional argument: %s", curArg)
+			if known {
+				unknown = append(unknown, curArg)
+				argsIndex++
+				break
+			}
+			return nil, parseErrorf("", "Unknown positional argument: %s", curArg)
 		case statePosArg:
-			if err := argSet.posArgs[posIndex].arg.value.Set(curArg); err != nil {
-				return fmt.Errorf("error while setting option '%s': %s", argSet.posArgs[posIndex].name, err)
+			posArg := argSet.posArgs[posIndex].arg
+			if err := argSet.setValidated(posArg, func() error { return posArg.value.Set(curArg) }); err != nil {
+				return nil, parseErrorf(argSet.posArgs[posIndex].name, "error while setting option '%s': %s", argSet.posArgs[posIndex].name, err)
+			}
+			visited[posArg] = true
+			argSet.warnDeprecated(posArg, argSet.posArgs[posIndex].name)
+			argSet.sources[posArg] = SourceCommandLine
+			if stop, err := argSet.runAction(posArg, argSet.posArgs[posIndex].name); stop || err != nil {
+				return unknown, err
 			}
-			visited[argSet.posArgs[posIndex].name] = true
 			posIndex++
 			argsIndex++
 			curState = stateInit
 		case stateOptArg:
+			// setValue calls Set on the first occurrence of curArg and Append on
+			// every later occurrence and then validates the resulting value
+			setValue := func(values ...string) error {
+				arg := argSet.optArgs[curArg]
+				return argSet.setValidated(arg, func() error {
+					if rv, ok := arg.value.(RepeatableValue); ok && visited[arg] {
+						return rv.Append(values...)
+					}
+					return arg.value.Set(values...)
+				})
+			}
 			if argSet.optArgs[curArg].nArgs == 0 {
-				if curArg == "--help" {
-					argSet.usage()
-					return nil
+				if err := setValue(); err != nil {
+					return nil, parseErrorf(curArg, "error while setting option '%s': %s", curArg, err)
 				}
-				argSet.optArgs[curArg].value.Set()
 				argsIndex++
 			} else if argSet.optArgs[curArg].nArgs < 0 {
-				if err := argSet.optArgs[curArg].value.Set(argsToParse[argsIndex+1:]...); err != nil {
-					return fmt.Errorf("error while setting option '%s': %s", curArg, err)
+				if err := setValue(argsToParse[argsIndex+1:]...); err != nil {
+					return nil, parseErrorf(curArg, "error while setting option '%s': %s", curArg, err)
 				}
 				argsIndex = len(argsToParse)
 
 			} else {
 				inp := []string{}
 				for i := 1; i <= argSet.optArgs[curArg].nArgs; i++ {
-					v := getArg(i + argsIndex)
-					if v == "" {
-						return fmt.Errorf("invalid no. of arguments for option '%s'; required: %d, given: %d", curArg, argSet.optArgs[curArg].nArgs, i-1)
+					if i+argsIndex >= len(argsToParse) {
+						return nil, parseErrorf(curArg, "invalid no. of arguments for option '%s'; required: %d, given: %d; usage: %s", curArg, argSet.optArgs[curArg].nArgs, i-1, argSet.synopsis(curArg, argSet.optArgs[curArg]))
 					}
-					inp = append(inp, v)
+					inp = append(inp, argsToParse[i+argsIndex])
 				}
-				if err := argSet.optArgs[curArg].value.Set(inp...); err != nil {
-					return fmt.Errorf("error while setting option '%s': %s", curArg, err)
+				if err := setValue(inp...); err != nil {
+					return nil, parseErrorf(curArg, "error while setting option '%s': %s", curArg, err)
 				}
 				argsIndex += argSet.optArgs[curArg].nArgs + 1
 			}
+			visited[argSet.optArgs[curArg]] = true
+			argSet.warnDeprecated(argSet.optArgs[curArg], curArg)
+			argSet.sources[argSet.optArgs[curArg]] = SourceCommandLine
+			if stop, err := argSet.runAction(argSet.optArgs[curArg], curArg); stop || err != nil {
+				return unknown, err
+			}
 			curState = stateInit
 		case stateNoArgsLeft:
+			if err := argSet.setFromEnv(); err != nil {
+				return nil, err
+			}
 			for _, pos := range argSet.posArgs {
-				if !visited[pos.name] {
-					return fmt.Errorf("Error: value for positional argument '%s' not given", pos.name)
+				if !visited[pos.arg] && argSet.sources[pos.arg] == SourceDefault {
+					return nil, parseErrorf(pos.name, "Error: value for positional argument '%s' not given", pos.arg.Metavar())
 				}
 			}
-			return nil
+			for _, arg := range argSet.args {
+				if arg.required && !arg.positional && argSet.sources[arg] == SourceDefault {
+					name := argSet.OptArgPrefix + arg.name
+					return nil, parseErrorf(name, "required option '%s' not given", argSet.synopsis(name, arg))
+				}
+			}
+			for _, fn := range argSet.validators {
+				if err := argSet.unlocked(func() error { return fn(argSet) }); err != nil {
+					if _, ok := err.(*ParseError); ok {
+						return nil, err
+					}
+					return nil, &ParseError{Err: err}
+				}
+			}
+			return unknown, nil
 		}
 	}
 }
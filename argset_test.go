@@ -1,6 +1,10 @@
 package argparser
 
 import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -20,20 +24,114 @@ func TestNewArgSet(t *testing.T) {
 
 func TestArgSetAdd(t *testing.T) {
 	argset := NewArgSet()
+	var pos1, opt1 int
 
-	argset.Add("dummy", nil)
-	if len(argset.posArgs) != 0 || argset.optArgs["--dummy"] != nil {
-		t.Errorf(`testing: argset.AddArgument("dummy", nil); expected: no positional/optional argument named 'dummy should get added; got: 'dummy' got added`)
+	if err := argset.Add("dummy", nil); err == nil || len(argset.posArgs) != 0 || argset.optArgs["--dummy"] != nil {
+		t.Errorf(`testing: argset.Add("dummy", nil); expected: error and no positional/optional argument named 'dummy' should get added; got: %v`, err)
 	}
 
-	argset.Add("pos1", NewPosArg(nil, ""))
+	if err := argset.Add("pos1", NewPosArg(nil, "")); err == nil {
+		t.Errorf(`testing: argset.Add("pos1", NewPosArg(nil, "")); expected: error; got: nil`)
+	}
+
+	argset.Add("pos1", NewPosArg(NewInt(&pos1), ""))
 	if len(argset.posArgs) == 0 || argset.posArgs[0].name != "pos1" {
-		t.Errorf(`testing: argset.AddArgument("pos1", NewPosArg(nil, "")); expected: argset.posArgs[0].name == "pos1"; got: len(argset.posArgs) == 0`)
+		t.Errorf(`testing: argset.Add("pos1", NewPosArg(NewInt(&pos1), "")); expected: argset.posArgs[0].name == "pos1"; got: len(argset.posArgs) == 0`)
 	}
 
-	argset.Add("opt1", NewOptArg(nil, ""))
+	argset.Add("opt1", NewOptArg(NewInt(&opt1), ""))
 	if argset.optArgs["--opt1"] == nil {
-		t.Errorf(`testing: argset.AddArgument("opt1", NewOptArg(nil, "")); expected: argset.optArgs["opt1"] != nil; got: argset.optArgs["opt1"] == nil`)
+		t.Errorf(`testing: argset.Add("opt1", NewOptArg(NewInt(&opt1), "")); expected: argset.optArgs["opt1"] != nil; got: argset.optArgs["opt1"] == nil`)
+	}
+}
+
+func TestArgSetAddInvalidDefinitions(t *testing.T) {
+	var num int
+	var list []int
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&num), ""))
+	argset.Add("file", NewPosArg(NewInt(&num), ""))
+
+	shared := NewOptArg(NewInt(&num), "")
+	argset.Add("shared", shared)
+	nargs3 := NewOptArg(NewInt(&num), "")
+	nargs3.SetNArgs(3)
+	nargsAll := NewOptArg(NewInt(&num), "")
+	nargsAll.SetNArgs(-1)
+	nargs0 := NewOptArg(NewInt(&num), "")
+	nargs0.SetNArgs(0)
+
+	invalid := []struct {
+		name string
+		arg  *Argument
+	}{
+		{"", NewOptArg(NewInt(&num), "")},
+		{"-x", NewOptArg(NewInt(&num), "")},
+		{"a b", NewOptArg(NewInt(&num), "")},
+		{"a=b", NewOptArg(NewInt(&num), "")},
+		{"num", NewOptArg(NewInt(&num), "")},
+		{"num", NewPosArg(NewInt(&num), "")},
+		{"file", NewPosArg(NewInt(&num), "")},
+		{"file", NewSwitchArg(NewBool(new(bool)), "")},
+		{"help", NewSwitchArg(NewBool(new(bool)), "")},
+		{"other", shared},
+		{"n3", nargs3},
+		{"nall", nargsAll},
+		{"n0", nargs0},
+		{"name", NewSwitchArg(NewString(new(string)), "")},
+	}
+	for _, data := range invalid {
+		if err := argset.Add(data.name, data.arg); err == nil {
+			t.Errorf("testing: Add(%q) with nargs %d; expected: error; got: nil", data.name, data.arg.nArgs)
+		}
+	}
+
+	valid := []struct {
+		name string
+		arg  *Argument
+	}{
+		{"dry_run", NewSwitchArg(NewBool(new(bool)), "")},
+		{"log.level", NewOptArg(NewInt(&num), "")},
+		{"2fa", NewOptArg(NewInt(&num), "")},
+		{"list", NewOptArg(NewIntList(&list), "")},
+		{"flag", NewSwitchArg(NewFlagValue(new(switchFlag)), "")},
+	}
+	valid[3].arg.SetNArgs(-1)
+	for _, data := range valid {
+		if err := argset.Add(data.name, data.arg); err != nil {
+			t.Errorf("testing: Add(%q); expected: no error; got: %v", data.name, err)
+		}
+	}
+}
+
+func TestNewArgSetFromInvalidDefinitions(t *testing.T) {
+	shadowsHelp := &struct {
+		Help bool `argparser:"type=switch"`
+	}{}
+	if _, err := NewArgSetFrom(shadowsHelp); err == nil || !strings.Contains(err.Error(), "'Help'") {
+		t.Errorf("testing: NewArgSetFrom(field named Help); expected: error naming the field; got: %v", err)
+	}
+
+	scalarNArgs := &struct {
+		Num int `argparser:"nargs=3"`
+	}{}
+	if _, err := NewArgSetFrom(scalarNArgs); err == nil {
+		t.Errorf("testing: NewArgSetFrom(scalar with nargs=3); expected: error; got: nil")
+	}
+
+	// Test that only boolean values can be switches
+	noValue := []interface{}{
+		&struct {
+			Num int `argparser:"nargs=0"`
+		}{},
+		&struct {
+			Name string `argparser:"type=switch"`
+		}{},
+	}
+	for _, input := range noValue {
+		if _, err := NewArgSetFrom(input); err == nil {
+			t.Errorf("testing: NewArgSetFrom(%#v); expected: error since only boolean values take no value; got: nil", input)
+		}
 	}
 }
 
@@ -115,3 +213,633 @@ func TestUsage(t *testing.T) {
 
 	argSet.usage()
 }
+
+func TestArgSetValidators(t *testing.T) {
+	var start, end, replicas int
+	var quorum bool
+	argset := NewArgSet()
+	argset.Add("start", NewOptArg(NewInt(&start), ""))
+	argset.Add("end", NewOptArg(NewInt(&end), ""))
+	argset.Add("replicas", NewOptArg(NewInt(&replicas), ""))
+	argset.Add("quorum", NewSwitchArg(NewBool(&quorum), ""))
+	argset.AddValidator(func(set *ArgSet) error {
+		if end <= start {
+			return &ParseError{Arg: "end", Err: fmt.Errorf("--end must be after --start")}
+		}
+		return nil
+	})
+	argset.AddValidator(func(set *ArgSet) error {
+		if set.IsSet("quorum") && replicas%2 == 0 {
+			return fmt.Errorf("--replicas must be odd when --quorum is set")
+		}
+		return nil
+	})
+
+	data := []struct {
+		input []string
+		arg   string
+		fails bool
+	}{
+		{[]string{"--start", "1", "--end", "2"}, "", false},
+		{[]string{"--start", "2", "--end", "1"}, "end", true},
+		{[]string{"--end", "2", "--replicas", "2"}, "", false},
+		{[]string{"--end", "2", "--replicas", "2", "--quorum"}, "", true},
+		{[]string{"--end", "2", "--replicas", "3", "--quorum"}, "", false},
+	}
+	for _, val := range data {
+		start, end, replicas, quorum = 0, 0, 0, false
+		argset.ArgList = val.input
+		err := argset.Parse()
+		if !val.fails {
+			if err != nil {
+				t.Errorf("testing: Parse(%q); expected: no error; got: %s", val.input, err)
+			}
+			continue
+		}
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("testing: Parse(%q); expected: *ParseError; got: %#v", val.input, err)
+			continue
+		}
+		if perr.Arg != val.arg {
+			t.Errorf("testing: Parse(%q); expected: ParseError.Arg==%q; got: %q", val.input, val.arg, perr.Arg)
+		}
+	}
+}
+
+func TestArgSetSource(t *testing.T) {
+	var pos, opt, other int
+	argset := NewArgSet()
+	argset.Add("pos", NewPosArg(NewInt(&pos), ""))
+	argset.Add("opt", NewOptArg(NewInt(&opt), ""))
+	argset.Add("other", NewOptArg(NewInt(&other), ""))
+
+	argset.ArgList = []string{"1", "--opt", "2"}
+	if err := argset.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]Source{"pos": SourceCommandLine, "opt": SourceCommandLine, "other": SourceDefault, "undefined": SourceDefault} {
+		if got := argset.Source(name); got != expected {
+			t.Errorf("testing: Source(%q); expected: %v; got: %v", name, expected, got)
+		}
+	}
+
+	// Test that provenance is reset by every call to Parse
+	argset.ArgList = []string{"1"}
+	if err := argset.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if argset.IsSet("opt") {
+		t.Errorf("testing: IsSet(\"opt\") after parsing without --opt; expected: false; got: true")
+	}
+
+	// Test that errors from Parse name the argument
+	argset.ArgList = []string{"1", "--opt", "x"}
+	if perr, ok := argset.Parse().(*ParseError); !ok || perr.Arg != "--opt" {
+		t.Errorf("testing: Parse(%q); expected: *ParseError with Arg==\"--opt\"; got: %#v", argset.ArgList, perr)
+	}
+}
+
+func TestArgumentActions(t *testing.T) {
+	var config string
+	var level, count int
+	var list bool
+	var calls []string
+	argset := NewArgSet()
+
+	configArg := NewOptArg(NewString(&config), "")
+	configArg.SetAction(func(set *ArgSet, value interface{}) error {
+		calls = append(calls, "config="+value.(string))
+		if value.(string) == "bad" {
+			return fmt.Errorf("cannot load config")
+		}
+		// emulate loading a config file which sets the level
+		level = 5
+		return nil
+	})
+	argset.Add("config", configArg)
+	argset.Add("level", NewOptArg(NewInt(&level), ""))
+
+	listArg := NewSwitchArg(NewBool(&list), "")
+	listArg.SetAction(func(set *ArgSet, value interface{}) error {
+		calls = append(calls, "list")
+		return fmt.Errorf("listed: %w", ErrStopParsing)
+	})
+	argset.Add("list", listArg)
+
+	countArg := NewPosArg(NewInt(&count), "")
+	countArg.SetAction(func(set *ArgSet, value interface{}) error {
+		calls = append(calls, fmt.Sprint("count=", value))
+		return nil
+	})
+	argset.Add("count", countArg)
+
+	// Test that later arguments override values set by an action
+	argset.ArgList = []string{"--config", "a.conf", "--level", "7", "3"}
+	if err := argset.Parse(); err != nil || level != 7 || fmt.Sprint(calls) != "[config=a.conf count=3]" {
+		t.Errorf("testing: Parse(%q); expected: level==7, calls==[config=a.conf count=3]; got: %d, %v, %v", argset.ArgList, level, calls, err)
+	}
+
+	// Test that values set by an action are kept if not given later
+	calls, level = nil, 0
+	argset.ArgList = []string{"3", "--config", "a.conf"}
+	if err := argset.Parse(); err != nil || level != 5 {
+		t.Errorf("testing: Parse(%q); expected: level==5; got: %d, %v", argset.ArgList, level, err)
+	}
+
+	// Test that a wrapped ErrStopParsing stops parsing without error or missing positional error
+	calls = nil
+	argset.ArgList = []string{"--list", "--level", "x"}
+	if err := argset.Parse(); err != nil || fmt.Sprint(calls) != "[list]" {
+		t.Errorf("testing: Parse(%q); expected: no error, calls==[list]; got: %v, %v", argset.ArgList, calls, err)
+	}
+
+	// Test that action errors are returned as *ParseError naming the argument
+	argset.ArgList = []string{"--config", "bad", "3"}
+	if perr, ok := argset.Parse().(*ParseError); !ok || perr.Arg != "--config" {
+		t.Errorf("testing: Parse(%q); expected: *ParseError with Arg==\"--config\"; got: %#v", argset.ArgList, perr)
+	}
+}
+
+func TestHelpStopsParsing(t *testing.T) {
+	var opt int
+	argset := NewArgSet()
+	argset.Add("opt", NewOptArg(NewInt(&opt), "opt help"))
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.ArgList = []string{"--help", "--opt", "1"}
+	if err := argset.Parse(); err != nil || opt != 0 {
+		t.Errorf("testing: Parse(%q); expected: no error and --opt not processed; got: %d, %v", argset.ArgList, opt, err)
+	}
+	if !strings.Contains(out.String(), "opt help") {
+		t.Errorf("testing: Parse(%q); expected: usage written to output; got: %q", argset.ArgList, out.String())
+	}
+}
+
+func TestParseKnown(t *testing.T) {
+	var verbose bool
+	var level int
+	var prog string
+	newArgSet := func() *ArgSet {
+		argset := NewArgSet()
+		argset.Add("verbose", NewSwitchArg(NewBool(&verbose), ""))
+		argset.Add("level", NewOptArg(NewInt(&level), ""))
+		argset.Add("prog", NewPosArg(NewString(&prog), ""))
+		return argset
+	}
+
+	data := []struct {
+		input    []string
+		stop     bool
+		expected []string
+	}{
+		{[]string{"ls", "--level", "1"}, false, []string{}},
+		{[]string{"--color", "ls", "-l", "--verbose", "dir"}, false, []string{"--color", "-l", "dir"}},
+		{[]string{"--verbose", "ls", "-l", "--level", "1", "--x"}, false, []string{"-l", "--x"}},
+		{[]string{"--verbose", "ls", "-l", "--level", "1", "--x"}, true, []string{"-l", "--level", "1", "--x"}},
+		{[]string{"--x", "ls", "--level", "2", "dir", "--level", "3"}, true, []string{"--x", "--level", "2", "dir", "--level", "3"}},
+		{[]string{"--color=always", "ls", "--verbose"}, false, []string{"--color=always"}},
+		{[]string{"--color=always", "--level", "1", "ls", "--verbose"}, true, []string{"--color=always", "--verbose"}},
+		{[]string{"-l", "ls"}, false, []string{"-l"}},
+	}
+	for _, val := range data {
+		verbose, level, prog = false, 0, ""
+		argset := newArgSet()
+		argset.StopAtNonOption = val.stop
+		argset.ArgList = val.input
+		got, err := argset.ParseKnown()
+		if err != nil {
+			t.Errorf("testing: ParseKnown(%q); expected: no error; got: %s", val.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(val.expected, got) {
+			t.Errorf("testing: ParseKnown(%q) with StopAtNonOption==%v; expected: %q; got: %q", val.input, val.stop, val.expected, got)
+		}
+		if prog != "ls" {
+			t.Errorf("testing: ParseKnown(%q); expected: prog==ls; got: %q", val.input, prog)
+		}
+	}
+
+	// Test that StopAtNonOption fills every declared positional argument from
+	// the arguments following the first non-option
+	var dir string
+	argset := newArgSet()
+	argset.Add("dir", NewPosArg(NewString(&dir), ""))
+	argset.StopAtNonOption = true
+	argset.ArgList = []string{"--level", "1", "ls", "--verbose", "-l", "x"}
+	got, err := argset.ParseKnown()
+	if err != nil || prog != "ls" || dir != "--verbose" || verbose || level != 1 || !reflect.DeepEqual(got, []string{"-l", "x"}) {
+		t.Errorf("testing: ParseKnown(%q) with StopAtNonOption; expected: prog==ls, dir==--verbose, [-l x] returned; got: %q, %q, %q, %v",
+			argset.ArgList, prog, dir, got, err)
+	}
+
+	// Test that a separate value of an unknown option is taken as a positional
+	argset.StopAtNonOption = false
+	argset.ArgList = []string{"--color", "always", "ls", "x"}
+	got, err = argset.ParseKnown()
+	if err != nil || prog != "always" || dir != "ls" || !reflect.DeepEqual(got, []string{"--color", "x"}) {
+		t.Errorf("testing: ParseKnown(%q); expected: prog==always, dir==ls, [--color x] returned; got: %q, %q, %q, %v",
+			argset.ArgList, prog, dir, got, err)
+	}
+
+	// Test that known arguments are still validated
+	argset = newArgSet()
+	for _, input := range [][]string{{"--level", "x", "ls"}, {"--unknown"}} {
+		argset.ArgList = input
+		if _, err := argset.ParseKnown(); err == nil {
+			t.Errorf("testing: ParseKnown(%q); expected: error; got: no error", input)
+		}
+	}
+
+	// Test that Parse still fails on unknown arguments, short ones included
+	for _, input := range [][]string{{"ls", "--unknown"}, {"-l", "ls"}} {
+		argset.ArgList = input
+		if err := argset.Parse(); err == nil || !strings.Contains(err.Error(), "unknown optional argument") {
+			t.Errorf("testing: Parse(%q); expected: unknown optional argument error; got: %v", input, err)
+		}
+	}
+}
+
+func TestParseArgsAndReset(t *testing.T) {
+	args := struct {
+		Num    int               `argparser:""`
+		Names  []string          `argparser:"nargs=2"`
+		Labels map[string]string `argparser:""`
+		Size   ByteSize          `argparser:""`
+		Mode   string            `argparser:"type=pos"`
+	}{
+		Num:    7,
+		Names:  []string{"a", "b"},
+		Labels: map[string]string{"x": "y"},
+		Size:   1 << 20,
+	}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argset.ArgList = []string{"--num", "100"}
+
+	input := []string{"--num", "1", "--names", "c", "d", "--labels", "k=v", "--size", "2K", "fast"}
+	if err := argset.ParseArgs(input); err != nil {
+		t.Fatalf("testing: ParseArgs(%q); expected: no error; got: %s", input, err)
+	}
+	if args.Num != 1 || !reflect.DeepEqual(args.Names, []string{"c", "d"}) || args.Labels["k"] != "v" || args.Size != 2048 || args.Mode != "fast" {
+		t.Errorf("testing: ParseArgs(%q); expected: all values set; got: %+v", input, args)
+	}
+	if !argset.IsSet("num") || argset.ArgList[1] != "100" {
+		t.Errorf("testing: ParseArgs(%q); expected: num set and ArgList untouched; got: %v, %q", input, argset.IsSet("num"), argset.ArgList)
+	}
+
+	argset.Reset()
+	expected := []interface{}{7, []string{"a", "b"}, map[string]string{"x": "y"}, ByteSize(1 << 20), ""}
+	got := []interface{}{args.Num, args.Names, args.Labels, args.Size, args.Mode}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("testing: Reset(); expected: %v; got: %v", expected, got)
+	}
+	if argset.IsSet("num") {
+		t.Errorf("testing: Reset(); expected: provenance forgotten; got: num still set")
+	}
+
+	// Test that modifying a value after Reset does not affect the saved default
+	args.Names[0] = "changed"
+	argset.Reset()
+	if args.Names[0] != "a" {
+		t.Errorf("testing: Reset(); expected: saved default not shared with destination; got: %v", args.Names)
+	}
+
+	// Test that the same ArgSet can parse again
+	if err := argset.ParseArgs([]string{"slow"}); err != nil || args.Mode != "slow" || args.Num != 7 {
+		t.Errorf("testing: ParseArgs([slow]) after Reset(); expected: Mode==slow, Num==7; got: %+v, %v", args, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	var num, extra int
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&num), ""))
+
+	clone := argset.Clone()
+	clone.Add("extra", NewOptArg(NewInt(&extra), ""))
+	if err := clone.ParseArgs([]string{"--num", "1", "--extra", "2"}); err != nil || num != 1 || extra != 2 {
+		t.Errorf("testing: clone.ParseArgs(); expected: num==1, extra==2; got: %d, %d, %v", num, extra, err)
+	}
+	if err := argset.ParseArgs([]string{"--extra", "2"}); err == nil {
+		t.Errorf("testing: argset.ParseArgs([--extra 2]); expected: error since --extra was only added to the clone; got: no error")
+	}
+	if argset.IsSet("num") {
+		t.Errorf("testing: argset.IsSet(\"num\"); expected: parsing the clone does not affect provenance of argset; got: true")
+	}
+}
+
+func TestArgumentsIntrospection(t *testing.T) {
+	var file string
+	var level int
+	var verbose bool
+	argset := NewArgSet()
+	argset.Add("file", NewPosArg(NewString(&file), "input file"))
+	level = 3
+	argset.Add("level", NewOptArg(NewInt(&level), "compression level"))
+	argset.Add("verbose", NewSwitchArg(NewBool(&verbose), ""))
+	level = 5
+
+	// Test that arguments are returned in declaration order, including help
+	expected := []struct {
+		name, typeName, def, help string
+		kind                      Kind
+		nargs                     int
+	}{
+		{"help", "bool", "false", "Show this help message and exit", KindSwitch, 0},
+		{"file", "string", "", "input file", KindPositional, 1},
+		{"level", "int", "3", "compression level", KindOptional, 1},
+		{"verbose", "bool", "false", "", KindSwitch, 0},
+	}
+	args := argset.Arguments()
+	if len(args) != len(expected) {
+		t.Fatalf("testing: Arguments(); expected: %d arguments; got: %d", len(expected), len(args))
+	}
+	for i, exp := range expected {
+		arg := args[i]
+		if arg.Name() != exp.name || arg.TypeName() != exp.typeName || arg.DefaultString() != exp.def ||
+			arg.Help() != exp.help || arg.Kind() != exp.kind || arg.NArgs() != exp.nargs {
+			t.Errorf("testing: Arguments()[%d]; expected: %v; got: %s %s %q %q %s %d", i, exp,
+				arg.Name(), arg.TypeName(), arg.DefaultString(), arg.Help(), arg.Kind(), arg.NArgs())
+		}
+		if len(arg.Aliases()) != 0 || arg.Env() != "" || arg.Group() != "" {
+			t.Errorf("testing: Arguments()[%d]; expected: no aliases, env or group; got: %v %q %q", i, arg.Aliases(), arg.Env(), arg.Group())
+		}
+	}
+
+	if argset.Lookup("level").Name() != args[2].Name() || argset.Lookup("file").Name() != args[1].Name() || argset.Lookup("nonexistent") != nil {
+		t.Errorf("testing: Lookup(); expected: arguments matching their names")
+	}
+
+	// Test that the returned arguments cannot be used to modify argset
+	argset.Lookup("level").SetShort("l")
+	args[2].SetChoices("1", "2")
+	if level := argset.Lookup("level"); level.Short() != "" || level.Choices() != nil {
+		t.Errorf("testing: modifying the result of Lookup() and Arguments(); expected: argset unchanged; got: %q, %v", level.Short(), level.Choices())
+	}
+}
+
+type testDBConfig struct {
+	Host string `argparser:"env=HOST"`
+	Port int    `argparser:"env=PORT"`
+}
+
+type testLogConfig struct {
+	Level string `argparser:"name=level"`
+}
+
+func TestNewArgSetFromNested(t *testing.T) {
+	config := struct {
+		testLogConfig
+		DB      testDBConfig
+		Replica testDBConfig `argparser:"prefix=ro,envprefix=READONLY"`
+		Flat    testDBConfig `argparser:"prefix="`
+		HTTP    struct {
+			TLS struct {
+				Cert string `argparser:"env=CERT"`
+			}
+		}
+		Addr HostPort `argparser:""`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom(nested structs); expected: no error; got: %v", err)
+	}
+
+	expected := map[string]string{
+		"level": "", "db-host": "DB_HOST", "db-port": "DB_PORT", "ro-host": "READONLY_HOST",
+		"host": "HOST", "port": "PORT", "http-tls-cert": "HTTP_TLS_CERT", "addr": "",
+	}
+	for name, env := range expected {
+		arg := argset.Lookup(name)
+		if arg == nil || arg.Env() != env {
+			t.Errorf("testing: NewArgSetFrom(nested structs); expected: argument '%s' bound to %q; got: %v", name, env, arg)
+		}
+	}
+
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("READONLY_HOST", "replica")
+	defer os.Unsetenv("DB_PORT")
+	defer os.Unsetenv("READONLY_HOST")
+	if err := argset.ParseArgs([]string{"--db-host", "primary", "--level", "debug"}); err != nil {
+		t.Fatalf("testing: ParseArgs; expected: no error; got: %v", err)
+	}
+	if config.DB.Host != "primary" || config.DB.Port != 5432 || config.Replica.Host != "replica" || config.Level != "debug" {
+		t.Errorf("testing: ParseArgs with environment; expected: values from command line and environment; got: %+v", config)
+	}
+	if argset.Source("db-host") != SourceCommandLine || argset.Source("db-port") != SourceEnv || argset.Source("ro-port") != SourceDefault {
+		t.Errorf("testing: Source(); expected: command line, environment, default; got: %s, %s, %s",
+			argset.Source("db-host"), argset.Source("db-port"), argset.Source("ro-port"))
+	}
+
+	// Test that the command line takes precedence and invalid values are reported
+	os.Setenv("DB_PORT", "x")
+	if err := argset.ParseArgs([]string{"--db-port", "1"}); err != nil || config.DB.Port != 1 {
+		t.Errorf("testing: ParseArgs with --db-port; expected: 1 from command line; got: %d, %v", config.DB.Port, err)
+	}
+	err = argset.ParseArgs(nil)
+	if perr, ok := err.(*ParseError); !ok || perr.Arg != "--db-port" || !strings.Contains(err.Error(), "'--db-port'") || !strings.Contains(err.Error(), "DB_PORT") {
+		t.Errorf("testing: ParseArgs with invalid DB_PORT; expected: error naming --db-port and DB_PORT; got: %v", err)
+	}
+}
+
+func TestNewArgSetFromNestedInvalid(t *testing.T) {
+	data := []interface{}{
+		&struct {
+			Num int `argparser:"prefix=x"`
+		}{},
+		&struct {
+			DB testDBConfig `argparser:"help=database"`
+		}{},
+		&struct {
+			DB testDBConfig `argparser:"prefix="`
+			testDBConfig
+		}{},
+		&struct {
+			DB struct {
+				Port complex64 `argparser:""`
+			}
+		}{},
+	}
+	for _, d := range data {
+		if _, err := NewArgSetFrom(d); err == nil {
+			t.Errorf("testing: NewArgSetFrom(%T); expected: error; got: nil", d)
+		}
+	}
+}
+
+func TestHiddenAndDeprecated(t *testing.T) {
+	var secret, workers, jobs int
+	var old bool
+	argset := NewArgSet()
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+
+	secretArg := NewOptArg(NewInt(&secret), "internal knob")
+	secretArg.SetHidden(true)
+	argset.Add("secret", secretArg)
+	jobsArg := NewOptArg(NewInt(&jobs), "number of jobs")
+	jobsArg.AddDeprecatedAlias("workers", "renamed in v2")
+	jobsArg.AddDeprecatedAlias("threads", "")
+	argset.Add("jobs", jobsArg)
+	oldArg := NewSwitchArg(NewBool(&old), "old behaviour")
+	oldArg.Deprecate("will be removed in v3")
+	argset.Add("old", oldArg)
+
+	// Test that hidden arguments and deprecated aliases are left out of help
+	argset.ParseArgs([]string{"--help"})
+	help := out.String()
+	if strings.Contains(help, "secret") || strings.Contains(help, "workers") || strings.Contains(help, "threads") {
+		t.Errorf("testing: --help; expected: hidden argument and deprecated aliases left out; got: %q", help)
+	}
+	if !strings.Contains(help, "--jobs") || !strings.Contains(help, "(Deprecated: will be removed in v3)") {
+		t.Errorf("testing: --help; expected: --jobs and deprecation note; got: %q", help)
+	}
+
+	out.Reset()
+	if err := argset.ParseArgs([]string{"--secret", "1", "--workers", "4", "--old"}); err != nil {
+		t.Fatalf("testing: ParseArgs with hidden and deprecated options; expected: no error; got: %v", err)
+	}
+	if secret != 1 || jobs != 4 || !old || !argset.IsSet("jobs") {
+		t.Errorf("testing: ParseArgs; expected: 1, 4, true; got: %d, %d, %v", secret, jobs, old)
+	}
+	expected := "Warning: option '--workers' is deprecated, use '--jobs' instead: renamed in v2\n" +
+		"Warning: option '--old' is deprecated: will be removed in v3\n"
+	if out.String() != expected {
+		t.Errorf("testing: ParseArgs warnings; expected: %q; got: %q", expected, out.String())
+	}
+
+	out.Reset()
+	argset.ParseArgs([]string{"--threads", "2"})
+	if out.String() != "Warning: option '--threads' is deprecated, use '--jobs' instead\n" {
+		t.Errorf("testing: ParseArgs(--threads 2) warning; got: %q", out.String())
+	}
+	if err := argset.ParseArgs([]string{"--jobs", "1", "--workers", "2"}); err == nil {
+		t.Errorf("testing: ParseArgs(--jobs 1 --workers 2); expected: already given error; got: nil")
+	}
+
+	// Test that no warning is written if the value of a deprecated option is invalid
+	out.Reset()
+	if err := argset.ParseArgs([]string{"--workers", "x"}); err == nil || strings.Contains(out.String(), "Warning") {
+		t.Errorf("testing: ParseArgs(--workers x); expected: error and no warning; got: %v, %q", err, out.String())
+	}
+
+	// Test that deprecated aliases take part in duplicate detection
+	clash := NewOptArg(NewInt(&workers), "")
+	clash.AddDeprecatedAlias("jobs", "")
+	if err := argset.Clone().Add("workers2", clash); err == nil {
+		t.Errorf("testing: Add with deprecated alias clashing with --jobs; expected: error; got: nil")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	config := struct {
+		DryRun bool   `argparser:"name=dry-run,type=switch,aliases=dryrun"`
+		Colour string `argparser:"aliases=color|colors,short=c"`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom() with aliases; expected: no error; got: %v", err)
+	}
+
+	// Test that aliases count as a single argument
+	if n := len(argset.Arguments()); n != 3 {
+		t.Errorf("testing: Arguments() with aliases; expected: 3 arguments; got: %d", n)
+	}
+	if colour := argset.Lookup("color"); colour == nil || colour.Name() != "colour" || !reflect.DeepEqual(colour.Aliases(), []string{"color", "colors"}) {
+		t.Errorf("testing: Lookup(color); expected: the colour argument; got: %v", colour)
+	}
+
+	if err := argset.ParseArgs([]string{"--dryrun", "--color", "red"}); err != nil || !config.DryRun || config.Colour != "red" || !argset.IsSet("colour") {
+		t.Errorf("testing: ParseArgs(--dryrun --color red); expected: values set; got: %+v, %v", config, err)
+	}
+	for _, args := range [][]string{{"--dry-run", "--dryrun"}, {"--colour", "a", "--colors", "b"}, {"-c", "a", "--color", "b"}} {
+		if err := argset.ParseArgs(args); err == nil || !strings.Contains(err.Error(), "already given") {
+			t.Errorf("testing: ParseArgs(%q); expected: already given error; got: %v", args, err)
+		}
+	}
+
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.ParseArgs([]string{"--help"})
+	if !strings.Contains(out.String(), "\n  --dry-run, --dryrun\n") || !strings.Contains(out.String(), "\n  -c, --colour, --color, --colors COLOUR\n") {
+		t.Errorf("testing: --help with aliases; expected: all names listed; got: %q", out.String())
+	}
+
+	// Test that aliases take part in duplicate detection
+	var other bool
+	clash := NewSwitchArg(NewBool(&other), "")
+	clash.AddAlias("other", "dryrun")
+	if err := argset.Clone().Add("other2", clash); err == nil {
+		t.Errorf("testing: Add with alias clashing with --dryrun; expected: error; got: nil")
+	}
+	self := NewSwitchArg(NewBool(&other), "")
+	self.AddAlias("same", "same")
+	if err := argset.Clone().Add("other3", self); err == nil {
+		t.Errorf("testing: Add with repeated alias; expected: error; got: nil")
+	}
+}
+
+func TestMetavar(t *testing.T) {
+	var ids []int
+	var rest []string
+	var level string
+	var input string
+	var verbose bool
+	argset := NewArgSet()
+	argset.name = "prog"
+	ida := NewOptArg(NewIntList(&ids), "")
+	ida.SetNArgs(3)
+	ida.SetRequired(true)
+	argset.Add("emp-id", ida)
+	resta := NewOptArg(NewStringList(&rest), "")
+	resta.SetNArgs(-1)
+	argset.Add("rest", resta)
+	levela := NewOptArg(NewString(&level), "")
+	levela.SetChoices("debug", "info")
+	argset.Add("level", levela)
+	argset.Add("input", NewPosArg(NewPath(&input, 0), ""))
+	argset.Add("verbose", NewSwitchArg(NewBool(&verbose), ""))
+
+	testData := []struct {
+		name        string
+		metavar     string
+		placeholder string
+	}{
+		{"emp-id", "EMP_ID", "EMP_ID EMP_ID EMP_ID"},
+		{"rest", "REST", "[REST...]"},
+		{"level", "{debug,info}", "{debug,info}"},
+		{"input", "PATH", "PATH"},
+		{"verbose", "", ""},
+	}
+	for _, data := range testData {
+		arg := argset.Lookup(data.name)
+		if arg.Metavar() != data.metavar || arg.Placeholder() != data.placeholder {
+			t.Errorf("testing: Metavar/Placeholder of %s; expected: %q, %q; got: %q, %q", data.name, data.metavar, data.placeholder, arg.Metavar(), arg.Placeholder())
+		}
+	}
+	ida.SetMetavar("ID")
+	if ida.Placeholder() != "ID ID ID" {
+		t.Errorf("testing: Placeholder with SetMetavar; expected: %q; got: %q", "ID ID ID", ida.Placeholder())
+	}
+
+	// Test that metavars are shown in the synopsis, option list and errors
+	o := &strings.Builder{}
+	argset.SetOutput(o)
+	argset.ParseArgs([]string{"--help"})
+	for _, expected := range []string{"Usage: prog [options] --emp-id ID ID ID PATH\n", "\n  --emp-id ID ID ID\n", "\n  --rest [REST...]\n", "\n  --verbose\n", "\n  PATH\n"} {
+		if !strings.Contains(o.String(), expected) {
+			t.Errorf("testing: help with metavars; expected: %q; got: %q", expected, o.String())
+		}
+	}
+	err := argset.ParseArgs([]string{"--emp-id", "1"})
+	if err == nil || !strings.Contains(err.Error(), "usage: --emp-id ID ID ID") {
+		t.Errorf("testing: too few values; expected: error showing usage; got: %v", err)
+	}
+	err = argset.ParseArgs([]string{"x"})
+	if err == nil || !strings.Contains(err.Error(), "'--emp-id ID ID ID' not given") {
+		t.Errorf("testing: missing required option; expected: error showing usage; got: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package argparser
+
+import (
+	"encoding"
+	"reflect"
+	"sync"
+)
+
+// Thread safety
+//
+// An ArgSet is defined by a single goroutine: arguments, validators and
+// fields like Usage must not be changed once parsing has started, and Add
+// returns error if called then. After that every method of ArgSet can be called
+// concurrently.
+//
+// Parse, ParseArgs and friends write through to the destination variables of
+// the arguments and are serialized, hence the destination variables must
+// only be read directly when no parse can be running. Get can be used at any
+// time instead. No lock is held while actions and validators run, hence they
+// may call any method of the ArgSet, including Parse and Reset. Another parse
+// may then run before the one calling them resumes, whether started by them or
+// by another goroutine. Source and IsSet of the ArgSet passed to them reflect
+// the parse in progress while those of the original ArgSet only change once it
+// is done.
+//
+// ParseSnapshot neither writes destination variables nor waits for other
+// parses. It parses into private copies of the values, starting from their
+// defaults, and returns them as a Snapshot. Actions and validators called by
+// ParseSnapshot receive an ArgSet holding those copies, so they should read
+// values using Get rather than the destination variables.
+
+// Cloner is implemented by values which need more than a copy of the variable
+// they point to in order to be copied, e.g. because they hold a pointer to the
+// real destination. Values which are not pointers and do not implement Cloner
+// are shared by the snapshots returned by ParseSnapshot.
+type Cloner interface {
+	Clone() Value
+}
+
+// cloneValue returns a copy of v which does not share storage with it
+func cloneValue(v Value) Value {
+	if c, ok := v.(Cloner); ok {
+		return c.Clone()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return v
+	}
+	c := reflect.New(rv.Type().Elem())
+	c.Elem().Set(copyValue(rv.Elem()))
+	return c.Interface().(Value)
+}
+
+// clonePointer returns a new pointer of the same type as p pointing to a copy
+// of the value pointed to by p. Types which can marshal themselves as text are
+// copied that way since they may share memory otherwise, e.g. big.Int.
+func clonePointer(p interface{}) interface{} {
+	rv := reflect.ValueOf(p)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return p
+	}
+	c := reflect.New(rv.Type().Elem())
+	if m, ok := p.(encoding.TextMarshaler); ok {
+		if u, ok := c.Interface().(encoding.TextUnmarshaler); ok {
+			if text, err := m.MarshalText(); err == nil && u.UnmarshalText(text) == nil {
+				return c.Interface()
+			}
+		}
+	}
+	c.Elem().Set(copyValue(rv.Elem()))
+	return c.Interface()
+}
+
+// view returns a copy of argSet to run a single parse on. If detached is true
+// the arguments of the view hold copies of their default values instead of
+// writing to the destination variables. It must be called with argSet.mu held.
+func (argSet *ArgSet) view(detached bool) *ArgSet {
+	v := *argSet
+	v.sources = nil
+	v.holdsParseMu = false
+	if !detached {
+		// share mu so that values are never set while argSet reads them
+		return &v
+	}
+	v.mu = new(sync.Mutex)
+
+	clones := make(map[*Argument]*Argument)
+	cloneArg := func(arg *Argument) *Argument {
+		if c, found := clones[arg]; found {
+			return c
+		}
+		c := *arg
+		if arg.defValue != nil {
+			c.value = cloneValue(arg.defValue)
+		}
+		clones[arg] = &c
+		return &c
+	}
+	v.posArgs = make([]posArgWithName, len(argSet.posArgs))
+	for i, p := range argSet.posArgs {
+		v.posArgs[i] = posArgWithName{name: p.name, arg: cloneArg(p.arg)}
+	}
+	v.optArgs = make(map[string]*Argument, len(argSet.optArgs))
+	for name, arg := range argSet.optArgs {
+		v.optArgs[name] = cloneArg(arg)
+	}
+	v.args = make([]*Argument, len(argSet.args))
+	for i, arg := range argSet.args {
+		v.args[i] = cloneArg(arg)
+	}
+	return &v
+}
+
+// Get returns the current value of the argument added with the given name, as
+// returned by Get() of its Value, or nil if there is no such argument. Unlike
+// reading the destination variable, it is safe to call while parsing.
+func (argSet *ArgSet) Get(name string) interface{} {
+	argSet.mu.Lock()
+	defer argSet.mu.Unlock()
+	arg := argSet.lookup(name)
+	if arg == nil || arg.value == nil {
+		return nil
+	}
+	return arg.value.Get()
+}
+
+// Snapshot holds the result of ParseSnapshot. It is not modified after being
+// returned and can be read by any number of goroutines.
+type Snapshot struct {
+	set *ArgSet
+}
+
+// ParseSnapshot parses args like ParseArgs but leaves the destination
+// variables untouched, returning the resulting values in a Snapshot instead.
+// Any number of calls to ParseSnapshot can run concurrently with each other
+// and with ParseArgs.
+func (argSet *ArgSet) ParseSnapshot(args []string) (*Snapshot, error) {
+	argSet.mu.Lock()
+	argSet.freeze()
+	view := argSet.view(true)
+	argSet.mu.Unlock()
+
+	if _, err := view.run(args, false); err != nil {
+		return nil, err
+	}
+	return &Snapshot{set: view}, nil
+}
+
+// Get returns the value of the argument added with the given name, or nil if
+// there is no such argument.
+func (s *Snapshot) Get(name string) interface{} {
+	return s.set.Get(name)
+}
+
+// Source returns where the value of the argument added with the given name
+// came from.
+func (s *Snapshot) Source(name string) Source {
+	return s.set.Source(name)
+}
+
+// IsSet reports whether the argument added with the given name was given.
+func (s *Snapshot) IsSet(name string) bool {
+	return s.set.IsSet(name)
+}
@@ -0,0 +1,243 @@
+package argparser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSnapshot(t *testing.T) {
+	var num int
+	var tags map[string]string
+	var path string
+	num = 7
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&num), ""))
+	argset.Add("tag", NewOptArg(NewStringMap(&tags), ""))
+	argset.Add("path", NewOptArg(NewPath(&path, 0), ""))
+
+	// Test that a snapshot holds the parsed values and destinations are untouched
+	snap, err := argset.ParseSnapshot([]string{"--num", "1", "--tag", "a=b", "--path", "x"})
+	if err != nil {
+		t.Fatalf("testing: ParseSnapshot; expected: no error; got: %v", err)
+	}
+	if num != 7 || tags != nil || path != "" {
+		t.Errorf("testing: ParseSnapshot; expected: destinations unchanged; got: %d, %v, %q", num, tags, path)
+	}
+	if snap.Get("num") != 1 || !reflect.DeepEqual(snap.Get("tag"), map[string]string{"a": "b"}) || snap.Get("path") != "x" {
+		t.Errorf("testing: ParseSnapshot; expected: parsed values; got: %v, %v, %v", snap.Get("num"), snap.Get("tag"), snap.Get("path"))
+	}
+	if !snap.IsSet("num") || snap.Source("num") != SourceCommandLine || argset.IsSet("num") {
+		t.Errorf("testing: ParseSnapshot; expected: sources recorded in snapshot only")
+	}
+
+	// Test that every snapshot starts from the defaults rather than from the
+	// values of an earlier parse
+	argset.ParseArgs([]string{"--num", "3"})
+	snap, _ = argset.ParseSnapshot(nil)
+	if snap.Get("num") != 7 || snap.IsSet("num") {
+		t.Errorf("testing: ParseSnapshot(nil) after ParseArgs; expected: default 7; got: %v", snap.Get("num"))
+	}
+	if snap.Get("nonexistent") != nil {
+		t.Errorf("testing: Snapshot.Get(nonexistent); expected: nil; got: %v", snap.Get("nonexistent"))
+	}
+
+	// Test that validators see the values being parsed through Get
+	argset.AddValidator(func(set *ArgSet) error {
+		if set.Get("num").(int) < 0 {
+			return fmt.Errorf("num cannot be negative")
+		}
+		return nil
+	})
+	if _, err := argset.ParseSnapshot([]string{"--num", "-1"}); err == nil {
+		t.Errorf("testing: ParseSnapshot with validator; expected: error; got: no error")
+	}
+}
+
+func TestAddAfterParse(t *testing.T) {
+	var a, b int
+	argset := NewArgSet()
+	argset.Add("a", NewOptArg(NewInt(&a), ""))
+	argset.ParseArgs(nil)
+	if err := argset.Add("b", NewOptArg(NewInt(&b), "")); err == nil || argset.Lookup("b") != nil {
+		t.Errorf("testing: Add after ParseArgs; expected: error; got: %v", err)
+	}
+}
+
+func TestConcurrentParse(t *testing.T) {
+	var num int
+	var list []string
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&num), ""))
+	argset.Add("list", NewOptArg(NewStringList(&list), ""))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 300)
+	for i := 0; i < 100; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			s := strconv.Itoa(i)
+			snap, err := argset.ParseSnapshot([]string{"--num", s, "--list", s})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if snap.Get("num") != i || !reflect.DeepEqual(snap.Get("list"), []string{s}) {
+				errs <- fmt.Errorf("snapshot %d got: %v, %v", i, snap.Get("num"), snap.Get("list"))
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			if err := argset.ParseArgs([]string{"--num", strconv.Itoa(i)}); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			argset.Get("num")
+			argset.IsSet("list")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("testing: concurrent parsing; expected: no error; got: %v", err)
+	}
+}
+
+func TestCallbacksUseOriginalArgSet(t *testing.T) {
+	var num int
+	argset := NewArgSet()
+	numArg := NewOptArg(NewInt(&num), "")
+	numArg.SetAction(func(*ArgSet, interface{}) error {
+		argset.Lookup("num")
+		argset.Source("num")
+		return nil
+	})
+	numArg.AddValidator(func(interface{}) error {
+		argset.Get("num")
+		return nil
+	})
+	argset.Add("num", numArg)
+	argset.AddValidator(func(*ArgSet) error {
+		argset.IsSet("num")
+		argset.Arguments()
+		return nil
+	})
+
+	// Test that callbacks calling methods of the ArgSet being parsed do not
+	// deadlock
+	done := make(chan error, 1)
+	go func() { done <- argset.ParseArgs([]string{"--num", "1"}) }()
+	select {
+	case err := <-done:
+		if err != nil || num != 1 {
+			t.Errorf("testing: ParseArgs with callbacks using the ArgSet; expected: num==1; got: %d, %v", num, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("testing: ParseArgs with callbacks using the ArgSet; expected: no deadlock")
+	}
+}
+
+func TestReentrantCallbacks(t *testing.T) {
+	var num int
+	var name string
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&num), ""))
+	nameArg := NewOptArg(NewString(&name), "")
+	nameArg.SetAction(func(*ArgSet, interface{}) error {
+		return argset.ParseArgs([]string{"--num", "2"})
+	})
+	argset.Add("name", nameArg)
+	resetArg := NewSwitchArg(NewBool(new(bool)), "")
+	resetArg.SetAction(func(set *ArgSet, _ interface{}) error {
+		set.Reset()
+		return nil
+	})
+	argset.Add("reset", resetArg)
+	argset.AddValidator(func(set *ArgSet) error {
+		if set.Get("num") != 1 {
+			return nil
+		}
+		_, err := set.ParseKnownArgs([]string{"--num", "3"})
+		return err
+	})
+
+	// Test that callbacks calling Parse or Reset of the ArgSet being parsed
+	// do not deadlock
+	data := []struct {
+		input []string
+		num   int
+	}{
+		{[]string{"--num", "1"}, 3},
+		{[]string{"--num", "1", "--name", "x"}, 2},
+		{[]string{"--num", "1", "--reset"}, 0},
+	}
+	for _, val := range data {
+		done := make(chan error, 1)
+		go func() { done <- argset.ParseArgs(val.input) }()
+		select {
+		case err := <-done:
+			if err != nil || num != val.num {
+				t.Errorf("testing: ParseArgs(%q) with re-entrant callbacks; expected: num==%d; got: %d, %v", val.input, val.num, num, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("testing: ParseArgs(%q) with re-entrant callbacks; expected: no deadlock", val.input)
+		}
+	}
+}
+
+func TestConcurrentSnapshotsWithFilesAndHelp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outPath := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(outPath, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var in InputFile
+	var out OutputFile
+	argset := NewArgSet()
+	argset.Add("in", NewOptArg(&in, ""))
+	argset.Add("out", NewOptArg(&out, ""))
+	output := &strings.Builder{}
+	argset.SetOutput(output)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			snap, err := argset.ParseSnapshot([]string{"--in", outPath, "--out", outPath})
+			if err != nil {
+				t.Errorf("testing: ParseSnapshot with files; expected: no error; got: %v", err)
+				return
+			}
+			if snap.Get("in").(*InputFile).File() != nil || snap.Get("out").(*OutputFile).File() != nil {
+				t.Errorf("testing: ParseSnapshot with files; expected: files not opened")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			argset.ParseSnapshot([]string{"--help"})
+		}()
+	}
+	wg.Wait()
+	if kept, _ := ioutil.ReadFile(outPath); string(kept) != "keep" {
+		t.Errorf("testing: ParseSnapshot with output file; expected: file untouched; got: %q", kept)
+	}
+	if n := strings.Count(output.String(), "Usage:"); n != 20 {
+		t.Errorf("testing: concurrent --help; expected: 20 usage messages; got: %d", n)
+	}
+}
@@ -2,6 +2,7 @@ package argparser
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,69 +13,251 @@ const (
 	tagKeyValueSep rune = '='
 )
 
-var validTags = map[string]*regexp.Regexp{
-	// "name":  regexp.MustCompile(fmt.Sprintf(`^(name)%s([[:alnum:]-]+)$`, tagKeyValueSep)),
-	"name":  regexp.MustCompile(fmt.Sprintf(`^name%c([[:alnum:]-]+)$`, tagKeyValueSep)),
-	"type":  regexp.MustCompile(fmt.Sprintf(`^type%c(pos|opt|switch)$`, tagKeyValueSep)),
-	"help":  regexp.MustCompile(fmt.Sprintf(`^help%c(.+)$`, tagKeyValueSep)),
-	"nargs": regexp.MustCompile(fmt.Sprintf(`^nargs%c(-?[[:digit:]]+)$`, tagKeyValueSep)),
-	// "mutex":      nil,
-	// "short":      nil,
+// TagError describes an invalid 'argparser' struct tag
+type TagError struct {
+	Field  string // name of the struct field, empty if unknown
+	Key    string // key the error applies to, empty if it applies to no single key
+	Offset int    // byte offset in the tag where the error was found
+	Err    error
 }
 
-func splitKV(src string, sep rune) []string {
-	backSlash := '\\'
-	parts := make([]string, 0)
+func (e *TagError) Error() string {
 	b := &strings.Builder{}
-	var prevRune rune
-	for _, curRune := range src {
-		switch {
-		// rune is a backSlash simply skip it
-		case curRune == backSlash:
-		// rune is a sep but it is not escaped by backSlash
-		case curRune == sep && prevRune != backSlash:
-			if b.Len() != 0 {
-				parts = append(parts, b.String())
-				b.Reset()
-			}
-		// rune is either not a sep/backslash or if it is a sep then it is escaped by backskash
-		default:
-			b.WriteRune(curRune)
+	if e.Field != "" {
+		fmt.Fprintf(b, "field '%s': ", e.Field)
+	}
+	if e.Key != "" {
+		fmt.Fprintf(b, "tag key '%s' ", e.Key)
+	} else {
+		b.WriteString("tag ")
+	}
+	fmt.Fprintf(b, "at offset %d: %s", e.Offset, e.Err)
+	return b.String()
+}
+
+func (e *TagError) Unwrap() error { return e.Err }
+
+// tagKeySpec describes the values accepted by a tag key
+type tagKeySpec struct {
+	boolean    bool // key can be given without a value, meaning true
+	allowEmpty bool // key accepts an empty value
+	check      func(string) error
+}
+
+func matching(re *regexp.Regexp, what string) func(string) error {
+	return func(v string) error {
+		if !re.MatchString(v) {
+			return fmt.Errorf("invalid value '%s': must be %s", v, what)
 		}
-		prevRune = curRune
+		return nil
 	}
-	// append any remaining runes between last sep and end of src
-	if b.Len() != 0 {
-		parts = append(parts, b.String())
+}
+
+var tagKeys = map[string]tagKeySpec{
+	"name": {check: matching(validArgName, "a letter or digit followed by letters, digits, '-', '_' or '.'")},
+	"type": {check: matching(regexp.MustCompile(`^(pos|opt|switch)$`), "one of pos, opt or switch")},
+	"help": {},
+	"nargs": {check: func(v string) error {
+		_, err := strconv.ParseInt(v, 0, strconv.IntSize)
+		return err
+	}},
+	"min": {check: func(v string) error {
+		_, err := strconv.ParseFloat(v, 64)
+		return err
+	}},
+	"max": {check: func(v string) error {
+		_, err := strconv.ParseFloat(v, 64)
+		return err
+	}},
+	"regex": {check: func(v string) error {
+		_, err := regexp.Compile(v)
+		return err
+	}},
+	"minlen":      {check: matching(regexp.MustCompile(`^[[:digit:]]+$`), "a non-negative integer")},
+	"maxlen":      {check: matching(regexp.MustCompile(`^[[:digit:]]+$`), "a non-negative integer")},
+	"nonempty":    {boolean: true},
+	"env":         {check: matching(regexp.MustCompile(`^[[:alnum:]_]+$`), "letters, digits or '_'")},
+	"prefix":      {allowEmpty: true, check: matching(regexp.MustCompile(`^[[:alnum:]_.-]*$`), "letters, digits, '-', '_' or '.'")},
+	"envprefix":   {allowEmpty: true, check: matching(regexp.MustCompile(`^[[:alnum:]_]*$`), "letters, digits or '_'")},
+	"short":       {check: matching(validShortName, "a single letter")},
+	"aliases":     {check: matching(regexp.MustCompile(`^[[:alnum:]][[:alnum:]_.-]*(\|[[:alnum:]][[:alnum:]_.-]*)*$`), "names separated by '|'")},
+	"default":     {allowEmpty: true},
+	"required":    {boolean: true},
+	"choices":     {},
+	"group":       {},
+	"hidden":      {boolean: true},
+	"metavar":     {},
+	"deprecated":  {allowEmpty: true},
+	"onduplicate": {check: matching(regexp.MustCompile(`^(overwrite|keepfirst|error)$`), "one of overwrite, keepfirst or error")},
+}
+
+// tagToken is a key and its optional value as found in a struct tag
+type tagToken struct {
+	key         string
+	value       string
+	hasValue    bool
+	keyOffset   int
+	valueOffset int
+}
+
+func isTagSpace(c byte) bool { return c == ' ' || c == '\t' }
+
+// separateTagKeys are the tag keys which can also be given as a struct tag
+// of their own, e.g. help:"..."
+var separateTagKeys = []string{"help", "group", "metavar"}
+
+// lexTags splits structTags into key/value pairs. Pairs are separated by ','
+// and a key is optionally followed by '=' and a value. A value can be quoted
+// with ' or " in which case it ends at the matching quote and '\' escapes the
+// next character, e.g. help='Comma, safe'. Otherwise it ends at the next ','
+// not escaped by '\', and '\\' stands for a single backslash while any other
+// backslash is kept as is. White space around keys is ignored.
+func lexTags(structTags string) ([]tagToken, error) {
+	tokens := make([]tagToken, 0)
+	s := structTags
+	pos := 0
+	skipSpace := func() {
+		for pos < len(s) && isTagSpace(s[pos]) {
+			pos++
+		}
+	}
+	for {
+		skipSpace()
+		if pos == len(s) {
+			return tokens, nil
+		}
+		if s[pos] == byte(tagSep) {
+			pos++
+			continue
+		}
+
+		tok := tagToken{keyOffset: pos}
+		for pos < len(s) && s[pos] != byte(tagSep) && s[pos] != byte(tagKeyValueSep) && !isTagSpace(s[pos]) {
+			pos++
+		}
+		tok.key = s[tok.keyOffset:pos]
+		if tok.key == "" {
+			return nil, &TagError{Offset: pos, Err: fmt.Errorf("missing key before '%c'", tagKeyValueSep)}
+		}
+		skipSpace()
+		if pos == len(s) || s[pos] == byte(tagSep) {
+			tokens = append(tokens, tok)
+			continue
+		}
+		if s[pos] != byte(tagKeyValueSep) {
+			return nil, &TagError{Key: tok.key, Offset: pos, Err: fmt.Errorf("expected '%c' or '%c' after key, got '%c'", tagKeyValueSep, tagSep, s[pos])}
+		}
+		pos++
+		tok.hasValue = true
+		tok.valueOffset = pos
+
+		b := &strings.Builder{}
+		if pos < len(s) && (s[pos] == '\'' || s[pos] == '"') {
+			quote := s[pos]
+			closed := false
+			for pos++; pos < len(s); pos++ {
+				if s[pos] == quote {
+					closed = true
+					pos++
+					break
+				}
+				if s[pos] == '\\' && pos+1 < len(s) {
+					pos++
+				}
+				b.WriteByte(s[pos])
+			}
+			if !closed {
+				return nil, &TagError{Key: tok.key, Offset: tok.valueOffset, Err: fmt.Errorf("unterminated %c quote", quote)}
+			}
+			skipSpace()
+			if pos < len(s) && s[pos] != byte(tagSep) {
+				return nil, &TagError{Key: tok.key, Offset: pos, Err: fmt.Errorf("expected '%c' after quoted value, got '%c'", tagSep, s[pos])}
+			}
+			tok.value = b.String()
+		} else {
+			for ; pos < len(s) && s[pos] != byte(tagSep); pos++ {
+				if isEscape(s, pos, string(tagSep)) {
+					pos++
+				}
+				b.WriteByte(s[pos])
+			}
+			tok.value = strings.TrimRight(b.String(), " \t")
+		}
+		tokens = append(tokens, tok)
 	}
-	return parts
 }
 
-func parseTags(structTags string) (map[string]string, error) {
+// parseTagOffsets parses structTags into a map of keys to values, verifying
+// every key and value, along with the offset of every key. Boolean keys are
+// mapped to "true" if set and left out otherwise.
+func parseTagOffsets(structTags string) (map[string]string, map[string]int, error) {
+	tokens, err := lexTags(structTags)
+	if err != nil {
+		return nil, nil, err
+	}
 	tagValues := make(map[string]string)
-	tags := splitKV(structTags, tagSep)
-	for _, tag := range tags {
-		unknownTag := true
-		for name, regex := range validTags {
-			res := regex.FindStringSubmatch(tag)
-			if len(res) == 2 {
-				tagValues[name] = res[1]
-				unknownTag = false
+	offsets := make(map[string]int)
+	for _, tok := range tokens {
+		spec, known := tagKeys[tok.key]
+		if !known {
+			return nil, nil, &TagError{Key: tok.key, Offset: tok.keyOffset, Err: fmt.Errorf("unknown key")}
+		}
+		if _, dup := offsets[tok.key]; dup {
+			return nil, nil, &TagError{Key: tok.key, Offset: tok.keyOffset, Err: fmt.Errorf("key given more than once")}
+		}
+		offsets[tok.key] = tok.keyOffset
+
+		if spec.boolean {
+			set := true
+			if tok.hasValue {
+				if set, err = strconv.ParseBool(tok.value); err != nil {
+					return nil, nil, &TagError{Key: tok.key, Offset: tok.valueOffset, Err: fmt.Errorf("invalid value '%s': must be true or false", tok.value)}
+				}
+			}
+			if set {
+				tagValues[tok.key] = "true"
 			}
+			continue
 		}
-		if unknownTag {
-			return nil, fmt.Errorf("unknown tag and/or invalid value: %s", tag)
+		if !tok.hasValue {
+			return nil, nil, &TagError{Key: tok.key, Offset: tok.keyOffset, Err: fmt.Errorf("value required")}
+		}
+		if tok.value == "" && !spec.allowEmpty {
+			return nil, nil, &TagError{Key: tok.key, Offset: tok.valueOffset, Err: fmt.Errorf("value cannot be empty")}
+		}
+		if spec.check != nil {
+			if err := spec.check(tok.value); err != nil {
+				return nil, nil, &TagError{Key: tok.key, Offset: tok.valueOffset, Err: err}
+			}
 		}
+		tagValues[tok.key] = tok.value
 	}
+	return tagValues, offsets, nil
+}
 
-	return tagValues, nil
+func parseTags(structTags string) (map[string]string, error) {
+	tagValues, _, err := parseTagOffsets(structTags)
+	return tagValues, err
 }
 
 func newArgFromTags(value Value, fieldName string, structTags string) (*Argument, string, error) {
-	tags, err := parseTags(structTags)
+	tags, offsets, err := parseTagOffsets(structTags)
 	if err != nil {
 		return nil, "", err
 	}
+	arg, name, err := newArgFromTagValues(value, fieldName, tags)
+	if te, ok := err.(*TagError); ok {
+		te.Offset = offsets[te.Key]
+	}
+	return arg, name, err
+}
+
+// newArgFromTagValues creates an argument from tags parsed by parseTagOffsets.
+// Errors specific to a key are returned as *TagError without an offset.
+func newArgFromTagValues(value Value, fieldName string, tags map[string]string) (*Argument, string, error) {
+	keyErr := func(key string, err error) error {
+		return &TagError{Key: key, Err: err}
+	}
 
 	// calculate name: if "name" not specified then simlpy use field's name in lower case
 	if tags["name"] == "" {
@@ -93,19 +276,156 @@ func newArgFromTags(value Value, fieldName string, structTags string) (*Argument
 
 	if tags["nargs"] != "" {
 		if newARg.isSwitch() {
-			return nil, "", fmt.Errorf("nargs can only be 0 for type=switch")
+			return nil, "", keyErr("nargs", fmt.Errorf("nargs can only be 0 for type=switch"))
 		}
 
 		nargs, err := strconv.ParseInt(tags["nargs"], 0, strconv.IntSize)
 		if err != nil {
-			return nil, "", formatParseError(tags["nargs"], fmt.Sprintf("%T", int(1)), err)
+			return nil, "", keyErr("nargs", formatParseError(tags["nargs"], fmt.Sprintf("%T", int(1)), err))
 		}
 
 		err = newARg.SetNArgs(int(nargs))
 		if err != nil {
-			return nil, "", err
+			return nil, "", keyErr("nargs", err)
+		}
+	}
+
+	for _, key := range []string{"prefix", "envprefix"} {
+		if _, found := tags[key]; found {
+			return nil, "", keyErr(key, fmt.Errorf("can only be used on struct fields"))
+		}
+	}
+
+	if policy := tags["onduplicate"]; policy != "" {
+		m, ok := value.(*Map)
+		if !ok {
+			return nil, "", keyErr("onduplicate", fmt.Errorf("can only be used on map fields"))
+		}
+		m.OnDuplicate = duplicateKeyPolicies[policy]
+	}
+
+	if def, found := tags["default"]; found && value != nil {
+		if newARg.positional {
+			return nil, "", keyErr("default", fmt.Errorf("positional arguments cannot have a default"))
+		}
+		values := []string{def}
+		if newARg.nArgs > 1 || newARg.nArgs < 0 {
+			values = strings.Fields(def)
+		}
+		// only check the values on a copy, they are set when parsing so that
+		// creating the argument has no side effects, like creating files
+		if err := cloneValue(value).Set(values...); err != nil {
+			return nil, "", keyErr("default", err)
 		}
+		newARg.defaults = values
+	}
+
+	if err := addConstraints(newARg, value, tags); err != nil {
+		return nil, "", err
+	}
+	if tags["choices"] != "" {
+		newARg.SetChoices(strings.Split(tags["choices"], "|")...)
+	}
+
+	if tags["short"] != "" {
+		if newARg.positional {
+			return nil, "", keyErr("short", fmt.Errorf("positional arguments cannot have a short name"))
+		}
+		newARg.SetShort(tags["short"])
+	}
+	if tags["aliases"] != "" {
+		if newARg.positional {
+			return nil, "", keyErr("aliases", fmt.Errorf("positional arguments cannot have aliases"))
+		}
+		newARg.AddAlias(strings.Split(tags["aliases"], "|")...)
+	}
+	newARg.SetEnv(tags["env"])
+	newARg.SetRequired(tags["required"] != "")
+	newARg.SetGroup(tags["group"])
+	newARg.SetHidden(tags["hidden"] != "")
+	newARg.SetMetavar(tags["metavar"])
+	if msg, found := tags["deprecated"]; found {
+		newARg.Deprecate(msg)
 	}
 
 	return newARg, tags["name"], nil
 }
+
+// addConstraints adds validators to arg for the constraint tags min, max,
+// regex, minlen, maxlen and nonempty
+func addConstraints(arg *Argument, value Value, tags map[string]string) error {
+	for _, key := range []string{"min", "max"} {
+		if tags[key] == "" {
+			continue
+		}
+		if err := checkConstraintType(value, true); err != nil {
+			return &TagError{Key: key, Err: err}
+		}
+		limit, err := strconv.ParseFloat(tags[key], 64)
+		if err != nil {
+			return &TagError{Key: key, Err: formatParseError(tags[key], fmt.Sprintf("%T", float64(1)), err)}
+		}
+		if key == "min" {
+			arg.AddValidator(Min(limit))
+		} else {
+			arg.AddValidator(Max(limit))
+		}
+	}
+
+	for _, key := range []string{"regex", "minlen", "maxlen"} {
+		if tags[key] == "" {
+			continue
+		}
+		if err := checkConstraintType(value, false); err != nil {
+			return &TagError{Key: key, Err: err}
+		}
+		if key == "regex" {
+			re, err := regexp.Compile(tags[key])
+			if err != nil {
+				return &TagError{Key: key, Err: err}
+			}
+			arg.AddValidator(Regexp(re))
+			continue
+		}
+		n, err := strconv.ParseInt(tags[key], 0, strconv.IntSize)
+		if err != nil {
+			return &TagError{Key: key, Err: formatParseError(tags[key], fmt.Sprintf("%T", int(1)), err)}
+		}
+		if key == "minlen" {
+			arg.AddValidator(MinLen(int(n)))
+		} else {
+			arg.AddValidator(MaxLen(int(n)))
+		}
+	}
+
+	if tags["nonempty"] != "" {
+		arg.AddValidator(NonEmpty())
+	}
+	return nil
+}
+
+// CheckTags returns every problem NewArgSetFrom would run into with the
+// 'argparser' struct tags of v, a struct or a pointer to one, like unknown keys,
+// invalid values or conflicting argument names, rather than just the first. It
+// is meant for tests, e.g.
+//
+//	for _, err := range argparser.CheckTags(Config{}) {
+//		t.Error(err)
+//	}
+//
+// v itself is never modified.
+func CheckTags(v interface{}) []error {
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return []error{fmt.Errorf("v must be a struct or a pointer to a struct")}
+	}
+	errs := make([]error, 0)
+	NewArgSet().addFields(reflect.New(typ).Elem(), fieldScope{}, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
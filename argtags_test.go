@@ -2,20 +2,57 @@ package argparser
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestSplitKV(t *testing.T) {
-	data := make(map[string][]string)
-	data[""] = []string{}
-	data[fmt.Sprintf("%[1]c%[1]c%[1]c", tagSep)] = []string{}
-	data[fmt.Sprintf("a%[1]cb%[1]cc%[1]cd", tagSep)] = []string{"a", "b", "c", "d"}
-	data[fmt.Sprintf("%[1]ca%[1]cb%[1]cc%[1]cd%[1]c", tagSep)] = []string{"a", "b", "c", "d"}
-	data[fmt.Sprintf("%[1]ca\\%[1]cb%[1]cc%[1]cd\\%[1]c", tagSep)] = []string{fmt.Sprintf("a%cb", tagSep), "c", fmt.Sprintf("d%c", tagSep)}
+func TestLexTags(t *testing.T) {
+	data := map[string][]tagToken{
+		"":    {},
+		",,,": {},
+		"a,b,c,d": {
+			{key: "a", keyOffset: 0}, {key: "b", keyOffset: 2}, {key: "c", keyOffset: 4}, {key: "d", keyOffset: 6},
+		},
+		",a, b ,": {{key: "a", keyOffset: 1}, {key: "b", keyOffset: 4}},
+		`a=x\,y,b=z\,`: {
+			{key: "a", value: "x,y", hasValue: true, valueOffset: 2},
+			{key: "b", value: "z,", hasValue: true, keyOffset: 7, valueOffset: 9},
+		},
+		`help='Comma, safe',nonempty`: {
+			{key: "help", value: "Comma, safe", hasValue: true, valueOffset: 5},
+			{key: "nonempty", keyOffset: 19},
+		},
+		`help="say \"hi\"" , regex=^\d+$`: {
+			{key: "help", value: `say "hi"`, hasValue: true, valueOffset: 5},
+			{key: "regex", value: `^\d+$`, hasValue: true, keyOffset: 20, valueOffset: 26},
+		},
+		"default=": {{key: "default", hasValue: true, valueOffset: 8}},
+		`a=x\\,b=c:\dir\`: {
+			{key: "a", value: `x\`, hasValue: true, valueOffset: 2},
+			{key: "b", value: `c:\dir\`, hasValue: true, keyOffset: 6, valueOffset: 8},
+		},
+	}
 	for input, expected := range data {
-		if got := splitKV(input, tagSep); !reflect.DeepEqual(expected, got) {
-			t.Errorf("testing: splitKV(%q, %c); expected: %q; Got: %q", input, tagSep, expected, got)
+		got, err := lexTags(input)
+		if err != nil || !reflect.DeepEqual(expected, got) {
+			t.Errorf("testing: lexTags(%q); expected: %+v; got: %+v, %v", input, expected, got, err)
+		}
+	}
+
+	invalid := map[string]int{
+		"help='unterminated": 5,
+		"help='a'b":          8,
+		"=value":             0,
+		"a b":                2,
+	}
+	for input, offset := range invalid {
+		_, err := lexTags(input)
+		if te, ok := err.(*TagError); !ok || te.Offset != offset {
+			t.Errorf("testing: lexTags(%q); expected: *TagError at offset %d; got: %v", input, offset, err)
 		}
 	}
 }
@@ -25,7 +62,11 @@ func TestParseTagsInvalidKeyValues(t *testing.T) {
 		"hello",
 		"help=",
 		"hello=hi",
-		"name=arg_name",
+		"name=-arg",
+		"nonempty=maybe",
+		"required=true,required",
+		"short=ab",
+		"min",
 		"type=OPT",
 		"nargs=1x",
 	}
@@ -217,3 +258,225 @@ func TestNewArgFromTagsValidInput(t *testing.T) {
 		}
 	}
 }
+
+func TestNewArgFromTagsConstraints(t *testing.T) {
+	valid := []struct {
+		value Value
+		tags  string
+		ok    []string
+		fail  []string
+	}{
+		{NewInt(new(int)), "min=1,max=10", []string{"1", "10"}, []string{"0", "11"}},
+		{NewIntList(new([]int)), "min=-1.5", []string{"-1"}, []string{"-2"}},
+		{NewString(new(string)), "regex=^[[:alpha:]]+$", []string{"abc"}, []string{"ab1"}},
+		{NewString(new(string)), "minlen=2,maxlen=3", []string{"ab", "abc"}, []string{"a", "abcd"}},
+		{NewStringList(new([]string)), "nonempty", []string{"a"}, []string{""}},
+	}
+	for _, input := range valid {
+		arg, _, err := newArgFromTags(input.value, "Field1", input.tags)
+		if err != nil {
+			t.Errorf("testing: newArgFromTags(%s); expected: no error; got: %s", input.tags, err)
+			continue
+		}
+		for _, v := range input.ok {
+			input.value.Set(v)
+			if err := arg.validate(); err != nil {
+				t.Errorf("testing: newArgFromTags(%s); expected: %q to be valid; got: %s", input.tags, v, err)
+			}
+		}
+		for _, v := range input.fail {
+			input.value.Set(v)
+			if err := arg.validate(); err == nil {
+				t.Errorf("testing: newArgFromTags(%s); expected: %q to be invalid; got: no error", input.tags, v)
+			}
+		}
+	}
+
+	invalid := []struct {
+		value Value
+		tags  string
+	}{
+		{NewString(new(string)), "min=1"},
+		{NewInt(new(int)), "maxlen=1"},
+		{NewInt(new(int)), "max=abc"},
+		{NewString(new(string)), "regex=[a-"},
+		{NewString(new(string)), "minlen=-1"},
+	}
+	for _, input := range invalid {
+		if arg, _, err := newArgFromTags(input.value, "Field1", input.tags); arg != nil || err == nil {
+			t.Errorf("testing: newArgFromTags(%s); expected: error; got: %#v, %#v", input.tags, arg, err)
+		}
+	}
+}
+
+func TestNewArgSetFromNewTagKeys(t *testing.T) {
+	config := struct {
+		Verbose bool     `argparser:"type=switch,short=v,help='Be verbose, very'"`
+		Level   string   `argparser:"default=info,choices=debug|info|warn,group=Logging,metavar=LEVEL"`
+		Token   string   `argparser:"required,hidden"`
+		Ports   []int    `argparser:"nargs=-1,default=80 443"`
+		Mode    string   `argparser:"required=false"`
+		Names   []string `argparser:"name=first_name.v2"`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom(); expected: no error; got: %v", err)
+	}
+	if config.Level != "" || config.Ports != nil {
+		t.Errorf("testing: NewArgSetFrom() with default; expected: defaults not applied before parsing; got: %+v", config)
+	}
+	verbose, level, token := argset.Lookup("verbose"), argset.Lookup("level"), argset.Lookup("token")
+	if verbose.Short() != "v" || verbose.Help() != "Be verbose, very" {
+		t.Errorf("testing: short and quoted help; got: %q, %q", verbose.Short(), verbose.Help())
+	}
+	if !reflect.DeepEqual(level.Choices(), []string{"debug", "info", "warn"}) || level.Group() != "Logging" || level.Metavar() != "LEVEL" || level.DefaultString() != "info" {
+		t.Errorf("testing: choices, group and metavar; got: %v, %q, %q, %q", level.Choices(), level.Group(), level.Metavar(), level.DefaultString())
+	}
+	if !token.Required() || !token.Hidden() || argset.Lookup("mode").Required() || argset.Lookup("first_name.v2") == nil {
+		t.Errorf("testing: required, hidden and relaxed names; got: %v, %v", token.Required(), token.Hidden())
+	}
+
+	if err := argset.ParseArgs([]string{"-v", "--level", "debug"}); err == nil || !strings.Contains(err.Error(), "--token") {
+		t.Errorf("testing: ParseArgs without required --token; expected: error; got: %v", err)
+	}
+	if err := argset.ParseArgs([]string{"--token", "x", "--level", "trace"}); err == nil {
+		t.Errorf("testing: ParseArgs with --level outside choices; expected: error; got: nil")
+	}
+	if err := argset.ParseArgs([]string{"-v", "--verbose", "--token", "x"}); err == nil {
+		t.Errorf("testing: ParseArgs with -v and --verbose; expected: already given error; got: nil")
+	}
+	if err := argset.ParseArgs([]string{"-v", "--token", "x"}); err != nil || !config.Verbose {
+		t.Errorf("testing: ParseArgs with -v; expected: Verbose set; got: %v, %v", config.Verbose, err)
+	}
+	if config.Level != "info" || !reflect.DeepEqual(config.Ports, []int{80, 443}) {
+		t.Errorf("testing: ParseArgs with default; expected: defaults applied; got: %+v", config)
+	}
+
+	// Test that Reset restores the defaults rather than the zero values
+	if err := argset.ParseArgs([]string{"--token", "x", "--level", "debug", "--ports", "8080"}); err != nil {
+		t.Fatalf("testing: ParseArgs with --level and --ports; expected: no error; got: %v", err)
+	}
+	argset.Reset()
+	if config.Level != "info" || !reflect.DeepEqual(config.Ports, []int{80, 443}) {
+		t.Errorf("testing: Reset() with default; expected: defaults applied; got: %+v", config)
+	}
+}
+
+func TestTagErrors(t *testing.T) {
+	data := []struct {
+		src    interface{}
+		field  string
+		key    string
+		offset int
+	}{
+		{&struct {
+			Num int `argparser:"help=x,nargz=1"`
+		}{}, "Num", "nargz", 7},
+		{&struct {
+			Num int `argparser:"min=1,max=ten"`
+		}{}, "Num", "max", 10},
+		{&struct {
+			Name string `argparser:"help=x,min=1"`
+		}{}, "Name", "min", 7},
+		{&struct {
+			DB struct {
+				Port int `argparser:"short=p,type=pos"`
+			}
+		}{}, "DB.Port", "short", 0},
+		{&struct {
+			Mode string `argparser:"type=pos,default=fast"`
+		}{}, "Mode", "default", 9},
+		{&struct {
+			DB testDBConfig `argparser:"prefix=x,env=DB"`
+		}{}, "DB", "env", 9},
+	}
+	for _, d := range data {
+		_, err := NewArgSetFrom(d.src)
+		te, ok := err.(*TagError)
+		if !ok || te.Field != d.field || te.Key != d.key || te.Offset != d.offset {
+			t.Errorf("testing: NewArgSetFrom(%T); expected: field %s, key %s, offset %d; got: %#v", d.src, d.field, d.key, d.offset, err)
+			continue
+		}
+		if !strings.Contains(te.Error(), fmt.Sprintf("field '%s': tag key '%s' at offset %d: ", d.field, d.key, d.offset)) {
+			t.Errorf("testing: TagError.Error(); got: %s", te)
+		}
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	type valid struct {
+		Num int `argparser:"min=1"`
+		DB  testDBConfig
+	}
+	if errs := CheckTags(valid{}); errs != nil {
+		t.Errorf("testing: CheckTags(valid); expected: no errors; got: %v", errs)
+	}
+
+	type invalid struct {
+		A    int    `argparser:"nargs=x"`
+		B    string `argparser:"help='unterminated"`
+		Help bool   `argparser:"type=switch"`
+		DB   struct {
+			Port int `argparser:"unknown"`
+		}
+	}
+	v := &invalid{A: 3}
+	errs := CheckTags(v)
+	if len(errs) != 4 || v.A != 3 {
+		t.Errorf("testing: CheckTags(invalid); expected: 4 errors and no changes to v; got: %q", errs)
+	}
+
+	// Test that checking a default does not create the file it names
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outPath := filepath.Join(dir, "out.txt")
+	typ := reflect.StructOf([]reflect.StructField{{
+		Name: "Out",
+		Type: reflect.TypeOf(OutputFile{}),
+		Tag:  reflect.StructTag(`argparser:"default=` + outPath + `"`),
+	}})
+	if errs := CheckTags(reflect.New(typ).Interface()); errs != nil {
+		t.Errorf("testing: CheckTags() with default output file; expected: no errors; got: %v", errs)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("testing: CheckTags() with default output file; expected: file not created; got: %v", err)
+	}
+
+	if errs := CheckTags(1); len(errs) != 1 {
+		t.Errorf("testing: CheckTags(1); expected: 1 error; got: %v", errs)
+	}
+}
+
+func TestSeparateHelpTags(t *testing.T) {
+	config := struct {
+		Output string `argparser:"short=o" help:"Write the result to this file, creating it if needed.\n\nUse '-' for stdout." metavar:"FILE" group:"Output"`
+		Level  int    `argparser:""`
+	}{}
+	argset, err := NewArgSetFrom(&config)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom() with separate tags; expected: no error; got: %v", err)
+	}
+	output := argset.Lookup("output")
+	if output.Help() != "Write the result to this file, creating it if needed.\n\nUse '-' for stdout." || output.Metavar() != "FILE" || output.Group() != "Output" {
+		t.Errorf("testing: separate tags; got: %q, %q, %q", output.Help(), output.Metavar(), output.Group())
+	}
+
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.HelpWidth = 40
+	argset.usage()
+	expected := "  -o, --output FILE\n\tWrite the result to this file,\n\tcreating it if needed.\n\n\tUse '-' for stdout.  (Default: )\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("testing: usage() with long help; expected: %q; got: %q", expected, out.String())
+	}
+
+	dup := &struct {
+		Level int `argparser:"help=x" help:"y"`
+	}{}
+	if _, err := NewArgSetFrom(dup); err == nil {
+		t.Errorf("testing: NewArgSetFrom() with help in both tags; expected: error; got: nil")
+	}
+}
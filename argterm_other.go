@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package argparser
+
+import "os"
+
+// terminalWidth returns 0 as the size of terminals cannot be queried on this
+// platform, the COLUMNS environment variable is used instead
+func terminalWidth(f *os.File) int { return 0 }
@@ -1,14 +1,80 @@
 package argparser
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrStopParsing can be returned, also wrapped, by an Action to stop parsing
+// any further arguments. Parse then returns nil, as it does after showing help.
+var ErrStopParsing = errors.New("stop parsing")
+
+// Action is called when an argument is encountered while parsing, right after
+// its value has been set and validated. value is the result of calling Get()
+// on the argument's value. Any error other than ErrStopParsing is returned by Parse.
+type Action func(argSet *ArgSet, value interface{}) error
+
+// Kind tells how an argument is given on the command line
+type Kind int
+
+const (
+	// KindPositional arguments are identified by their position
+	KindPositional Kind = iota
+	// KindOptional arguments are given by name followed by their values
+	KindOptional
+	// KindSwitch arguments are given by name alone
+	KindSwitch
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindPositional:
+		return "positional"
+	case KindOptional:
+		return "optional"
+	case KindSwitch:
+		return "switch"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// MarshalText encodes k as returned by String
+func (k Kind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
 type Argument struct {
+	name       string // set by ArgSet.Add
 	value      Value
 	help       string
 	positional bool
 	nArgs      int // TODO: convert to string for patterns like '*', '+' etc.
+	validators []Validator
+	action     Action
+	restore    func()   // restores the value to its default, set by ArgSet.Add
+	defValue   Value    // detached copy of the default value, set by ArgSet.Add
+	defString  string   // String() of the default value, set by ArgSet.Add
+	defaults   []string // values of the 'default' tag, set at the start of every parse
+	aliases    []string
+	short      string
+	env        string
+	group      string
+	required   bool
+	hidden     bool
+	metavar    string
+	choices    []string
+	// deprecation is the message shown when the argument is used, if deprecated
+	deprecation       *string
+	deprecatedAliases []deprecatedAlias
+	// builtin is true for arguments added by ArgSet itself which give way to
+	// arguments of the same name added by the user, like --version
+	builtin bool
+}
+
+// deprecatedAlias is an old name of an argument which still works but shows a
+// deprecation message when used
+type deprecatedAlias struct {
+	name string
+	msg  string
 }
 
 func NewPosArg(value Value, help string) *Argument {
@@ -49,3 +115,226 @@ func (arg *Argument) SetNArgs(n int) error {
 	arg.nArgs = n
 	return nil
 }
+
+// AddValidator adds fn to the validators which are called, in the order they
+// were added, with the argument's value every time it is set while parsing.
+// If a validator fails the value is restored to what it was before.
+func (arg *Argument) AddValidator(fn Validator) {
+	arg.validators = append(arg.validators, fn)
+}
+
+func (arg *Argument) validate() error {
+	for _, fn := range arg.validators {
+		if err := fn(arg.value.Get()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAction sets fn as the action called whenever the argument is encountered
+// while parsing.
+func (arg *Argument) SetAction(fn Action) {
+	arg.action = fn
+}
+
+// describe returns a copy of arg, which does not share any state with arg that
+// its methods could modify, to be handed out by Lookup and Arguments
+func (arg *Argument) describe() *Argument {
+	c := *arg
+	c.validators = append([]Validator(nil), arg.validators...)
+	c.aliases = append([]string(nil), arg.aliases...)
+	c.choices = append([]string(nil), arg.choices...)
+	c.deprecatedAliases = append([]deprecatedAlias(nil), arg.deprecatedAliases...)
+	return &c
+}
+
+// Name returns the name the argument was added with, without OptArgPrefix
+func (arg *Argument) Name() string { return arg.name }
+
+// AddAlias adds alternative names for an optional argument, e.g. 'dryrun' for
+// 'dry-run'. Aliases are given with OptArgPrefix like the argument's name and
+// refer to the same argument, e.g. giving both is the same as giving the
+// argument twice.
+func (arg *Argument) AddAlias(names ...string) {
+	arg.aliases = append(arg.aliases, names...)
+}
+
+// Aliases returns the alternative names of the argument, without OptArgPrefix
+func (arg *Argument) Aliases() []string { return append([]string(nil), arg.aliases...) }
+
+// Kind returns whether the argument is positional, optional or a switch
+func (arg *Argument) Kind() Kind {
+	switch {
+	case arg.positional:
+		return KindPositional
+	case arg.isSwitch():
+		return KindSwitch
+	}
+	return KindOptional
+}
+
+// NArgs returns the number of values taken by the argument, negative meaning
+// all remaining values
+func (arg *Argument) NArgs() int { return arg.nArgs }
+
+// Help returns the help message of the argument
+func (arg *Argument) Help() string { return arg.help }
+
+// DefaultString returns the default value of the argument as formatted by its
+// String() method when the argument was added to an ArgSet
+func (arg *Argument) DefaultString() string { return arg.defString }
+
+// SetEnv binds the argument to the environment variable name, which is used
+// as its value when the argument is not given on the command line. An empty
+// name removes the binding.
+func (arg *Argument) SetEnv(name string) {
+	arg.env = name
+}
+
+// Env returns the name of the environment variable the argument is bound to,
+// or an empty string if there is none
+func (arg *Argument) Env() string { return arg.env }
+
+// SetShort sets a single letter name for the argument which can be given with
+// a single '-' prefix, e.g. -v for --verbose
+func (arg *Argument) SetShort(name string) {
+	arg.short = name
+}
+
+// Short returns the single letter name of the argument or an empty string
+func (arg *Argument) Short() string { return arg.short }
+
+// SetRequired sets whether parsing fails when an optional argument is given
+// neither on the command line nor by its environment variable. Positional
+// arguments are always required.
+func (arg *Argument) SetRequired(required bool) {
+	arg.required = required
+}
+
+// Required reports whether the argument must be given
+func (arg *Argument) Required() bool { return arg.required || arg.positional }
+
+// SetChoices restricts the values of the argument to choices, compared with
+// the elements of its value formatted by fmt.Sprint
+func (arg *Argument) SetChoices(choices ...string) {
+	arg.choices = append([]string(nil), choices...)
+	arg.AddValidator(OneOf(choices...))
+}
+
+// Choices returns the values accepted by the argument, set by SetChoices or
+// restricted by its value like Enum does, or nil if any value is accepted
+func (arg *Argument) Choices() []string {
+	if arg.choices != nil {
+		return append([]string(nil), arg.choices...)
+	}
+	if c, ok := arg.value.(interface{ Choices() []string }); ok {
+		return c.Choices()
+	}
+	return nil
+}
+
+// SetGroup sets the name of the help group the argument belongs to
+func (arg *Argument) SetGroup(name string) {
+	arg.group = name
+}
+
+// SetHidden sets whether the argument is left out of help. Hidden arguments
+// are parsed like any other.
+func (arg *Argument) SetHidden(hidden bool) {
+	arg.hidden = hidden
+}
+
+// Hidden reports whether the argument is left out of help
+func (arg *Argument) Hidden() bool { return arg.hidden }
+
+// Deprecate marks the argument as deprecated. It still works but using it on
+// the command line writes a warning, including msg if not empty, to the output
+// of the ArgSet.
+func (arg *Argument) Deprecate(msg string) {
+	arg.deprecation = &msg
+}
+
+// Deprecation returns the message given to Deprecate and whether the argument
+// is deprecated
+func (arg *Argument) Deprecation() (string, bool) {
+	if arg.deprecation == nil {
+		return "", false
+	}
+	return *arg.deprecation, true
+}
+
+// AddDeprecatedAlias adds an old name for an optional argument, e.g. after
+// renaming it. Giving the old name on the command line sets the argument as
+// usual but writes a warning, including msg if not empty, pointing to the new
+// name. Deprecated aliases are not shown in help.
+func (arg *Argument) AddDeprecatedAlias(alias string, msg string) {
+	arg.deprecatedAliases = append(arg.deprecatedAliases, deprecatedAlias{name: alias, msg: msg})
+}
+
+// DeprecatedAliases returns the names added with AddDeprecatedAlias
+func (arg *Argument) DeprecatedAliases() []string {
+	names := make([]string, len(arg.deprecatedAliases))
+	for i, a := range arg.deprecatedAliases {
+		names[i] = a.name
+	}
+	return names
+}
+
+// SetMetavar sets the placeholder shown for the argument's values in help
+func (arg *Argument) SetMetavar(name string) {
+	arg.metavar = name
+}
+
+// Metavar returns the placeholder shown for a single value of the argument.
+// Unless set with SetMetavar it is derived from the argument: values
+// implementing 'Metavar() string' suggest their own, e.g. FILE for InputFile,
+// choices are shown as {a,b}, positional arguments use their name and
+// optional arguments their name in upper case, e.g. EMP_ID for 'emp-id'.
+// Switches have no metavar.
+func (arg *Argument) Metavar() string {
+	switch {
+	case arg.metavar != "":
+		return arg.metavar
+	case arg.isSwitch():
+		return ""
+	}
+	if m, ok := arg.value.(interface{ Metavar() string }); ok && m.Metavar() != "" {
+		return m.Metavar()
+	}
+	if choices := arg.Choices(); len(choices) > 0 {
+		return "{" + strings.Join(choices, ",") + "}"
+	}
+	if arg.positional {
+		return arg.name
+	}
+	return envName(arg.name)
+}
+
+// Placeholder returns the metavar repeated as many times as the argument takes
+// values, e.g. 'ID ID ID' for nargs=3, or '[ID...]' if it takes all
+// remaining values. It is what help, error messages and completion hints show
+// after the name of an optional argument.
+func (arg *Argument) Placeholder() string {
+	metavar := arg.Metavar()
+	switch {
+	case metavar == "" || arg.nArgs == 0:
+		return ""
+	case arg.nArgs < 0:
+		return "[" + metavar + "...]"
+	}
+	return strings.TrimSuffix(strings.Repeat(metavar+" ", arg.nArgs), " ")
+}
+
+// Group returns the name of the help group the argument belongs to, or an
+// empty string if it belongs to none
+func (arg *Argument) Group() string { return arg.group }
+
+// TypeName returns the name of the Go type of the argument's value, as
+// returned by Get(), e.g. 'int' or '[]string'
+func (arg *Argument) TypeName() string {
+	if arg.value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", arg.value.Get())
+}
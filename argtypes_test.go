@@ -42,3 +42,14 @@ func TestSetNArgs(t *testing.T) {
 		t.Errorf("Expected: for optional argument %[1]T.SetNArgs(0) suceeds with no error setting %[1]T.nArgs==0; Got: error", optArg)
 	}
 }
+
+func TestSetAction(t *testing.T) {
+	arg := NewOptArg(nil, "")
+	if arg.action != nil {
+		t.Errorf("Expected: no action by default; Got: %p", arg.action)
+	}
+	arg.SetAction(func(*ArgSet, interface{}) error { return nil })
+	if arg.action == nil {
+		t.Errorf("Expected: action set by SetAction; Got: nil")
+	}
+}
@@ -0,0 +1,233 @@
+package argparser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator checks the value of an argument after it has been set while parsing.
+// v is the result of calling Get() on the argument's value.
+type Validator func(v interface{}) error
+
+// elements returns the elements of v if it is a list or a map, otherwise it
+// returns v itself. Byte slices like net.IP are not treated as lists.
+func elements(v interface{}) []reflect.Value {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		elems := make([]reflect.Value, rv.Len())
+		for i := range elems {
+			elems[i] = rv.Index(i)
+		}
+		return elems
+	case reflect.Map:
+		elems := make([]reflect.Value, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elems = append(elems, iter.Value())
+		}
+		return elems
+	}
+	return []reflect.Value{rv}
+}
+
+// elementType returns the type of the elements of a value of type typ as
+// considered by elements
+func elementType(typ reflect.Type) reflect.Type {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() != reflect.Uint8 {
+			return typ.Elem()
+		}
+	case reflect.Map:
+		return typ.Elem()
+	}
+	return typ
+}
+
+func isNumeric(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+func isTextual(typ reflect.Type) bool {
+	return typ.Kind() == reflect.String || typ.Implements(stringerType)
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func toText(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	if v.IsValid() && v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String(), true
+	}
+	return "", false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// numericValidator returns a Validator calling check for every numeric element
+func numericValidator(check func(float64) error) Validator {
+	return func(v interface{}) error {
+		for _, elem := range elements(v) {
+			f, ok := toFloat(elem)
+			if !ok {
+				return fmt.Errorf("value of type %s is not numeric", elem.Type())
+			}
+			if err := check(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// textValidator returns a Validator calling check for every textual element
+func textValidator(check func(string) error) Validator {
+	return func(v interface{}) error {
+		for _, elem := range elements(v) {
+			s, ok := toText(elem)
+			if !ok {
+				return fmt.Errorf("value of type %s is not a string", elem.Type())
+			}
+			if err := check(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Min returns a Validator requiring every numeric element to be >= min
+func Min(min float64) Validator {
+	return numericValidator(func(f float64) error {
+		if f < min {
+			return fmt.Errorf("value %s is less than minimum %s", formatNumber(f), formatNumber(min))
+		}
+		return nil
+	})
+}
+
+// Max returns a Validator requiring every numeric element to be <= max
+func Max(max float64) Validator {
+	return numericValidator(func(f float64) error {
+		if f > max {
+			return fmt.Errorf("value %s is greater than maximum %s", formatNumber(f), formatNumber(max))
+		}
+		return nil
+	})
+}
+
+// Regexp returns a Validator requiring every string element to match re
+func Regexp(re *regexp.Regexp) Validator {
+	return textValidator(func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("value '%s' does not match pattern '%s'", s, re)
+		}
+		return nil
+	})
+}
+
+// MinLen returns a Validator requiring every string element to have at least n characters
+func MinLen(n int) Validator {
+	return textValidator(func(s string) error {
+		if utf8.RuneCountInString(s) < n {
+			return fmt.Errorf("value '%s' is shorter than %d characters", s, n)
+		}
+		return nil
+	})
+}
+
+// MaxLen returns a Validator requiring every string element to have at most n characters
+func MaxLen(n int) Validator {
+	return textValidator(func(s string) error {
+		if utf8.RuneCountInString(s) > n {
+			return fmt.Errorf("value '%s' is longer than %d characters", s, n)
+		}
+		return nil
+	})
+}
+
+// OneOf returns a Validator requiring every element, formatted with
+// fmt.Sprint, to be one of choices
+func OneOf(choices ...string) Validator {
+	return func(v interface{}) error {
+		for _, elem := range elements(v) {
+			s := fmt.Sprint(elem.Interface())
+			found := false
+			for _, c := range choices {
+				if s == c {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("value '%s' must be one of: %s", s, strings.Join(choices, ", "))
+			}
+		}
+		return nil
+	}
+}
+
+// NonEmpty returns a Validator rejecting empty strings as well as empty lists
+// and maps
+func NonEmpty() Validator {
+	return func(v interface{}) error {
+		rv := reflect.ValueOf(v)
+		if k := rv.Kind(); (k == reflect.Slice || k == reflect.Map) && rv.Len() == 0 {
+			return fmt.Errorf("value cannot be empty")
+		}
+		for _, elem := range elements(v) {
+			if s, ok := toText(elem); ok && s == "" {
+				return fmt.Errorf("value cannot be empty")
+			}
+		}
+		return nil
+	}
+}
+
+// checkConstraintType verifies that a numeric or textual constraint can be
+// applied to the elements of value. Values whose Get() returns nil are not checked.
+func checkConstraintType(value Value, numeric bool) error {
+	if value == nil || value.Get() == nil {
+		return nil
+	}
+	typ := elementType(reflect.TypeOf(value.Get()))
+	if numeric && !isNumeric(typ) {
+		return fmt.Errorf("constraint requires a numeric type, got %s", typ)
+	}
+	if !numeric && !isTextual(typ) {
+		return fmt.Errorf("constraint requires a string type, got %s", typ)
+	}
+	return nil
+}
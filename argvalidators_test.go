@@ -0,0 +1,73 @@
+package argparser
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	data := []struct {
+		validator Validator
+		valid     []interface{}
+		invalid   []interface{}
+	}{
+		{Min(1), []interface{}{1, 2.5, uint8(1), []int{1, 2}, map[string]int{"a": 3}}, []interface{}{0, -1.5, []int{1, 0}, "a"}},
+		{Max(10), []interface{}{10, -1, []float64{1, 9.9}}, []interface{}{11, 10.1, []int{1, 11}, true}},
+		{Regexp(regexp.MustCompile(`^[a-z]+$`)), []interface{}{"abc", []string{"a", "b"}}, []interface{}{"abc1", []string{"a", "B"}, 1}},
+		{MinLen(2), []interface{}{"ab", "ééé", []string{"ab", "abc"}}, []interface{}{"a", "é", []string{"ab", "a"}}},
+		{MaxLen(2), []interface{}{"ab", "éé", []string{"a", ""}}, []interface{}{"abc", []string{"ab", "abc"}}},
+		{NonEmpty(), []interface{}{"a", []string{"a"}, []int{0}, net.IPv4zero}, []interface{}{"", []string{}, []string{"a", ""}, map[string]string{}, net.IP{}}},
+	}
+
+	for i, val := range data {
+		for _, input := range val.valid {
+			if err := val.validator(input); err != nil {
+				t.Errorf("testing: validator %d; expected: no error for %#v; got: %s", i, input, err)
+			}
+		}
+		for _, input := range val.invalid {
+			if err := val.validator(input); err == nil {
+				t.Errorf("testing: validator %d; expected: error for %#v; got: no error", i, input)
+			}
+		}
+	}
+}
+
+func TestArgumentValidatorInParse(t *testing.T) {
+	var port int
+	var name string
+	argset := NewArgSet()
+	portArg := NewOptArg(NewInt(&port), "port")
+	portArg.AddValidator(Min(1))
+	portArg.AddValidator(Max(65535))
+	argset.Add("port", portArg)
+	nameArg := NewPosArg(NewString(&name), "name")
+	nameArg.AddValidator(Regexp(regexp.MustCompile(`^[a-z]+$`)))
+	argset.Add("name", nameArg)
+
+	argset.ArgList = []string{"--port", "8080", "abc"}
+	if err := argset.Parse(); err != nil {
+		t.Errorf("testing: Parse(%q); expected: no error; got: %s", argset.ArgList, err)
+	}
+
+	for _, input := range [][]string{
+		{"--port", "70000", "abc"},
+		{"--port", "0", "abc"},
+		{"ABC"},
+	} {
+		argset.ArgList = input
+		err := argset.Parse()
+		if err == nil {
+			t.Errorf("testing: Parse(%q); expected: validation error; got: no error", input)
+			continue
+		}
+		if !strings.Contains(err.Error(), "'--port'") && !strings.Contains(err.Error(), "'name'") {
+			t.Errorf("testing: Parse(%q); expected: error naming the argument; got: %s", input, err)
+		}
+		if port != 8080 || name != "abc" {
+			t.Errorf("testing: Parse(%q); expected: rejected value not stored; got: %d, %q", input, port, name)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package argparser
 
 import (
+	"encoding"
+	"flag"
 	"fmt"
+	"net"
+	"net/url"
+	"reflect"
 	"strconv"
 )
 
@@ -13,8 +18,19 @@ type Value interface {
 
 // NewValue checks v's type and returns a compatible type which also
 // implements ArgValue interface. All supported types are pointer to some type.
+// Types implementing flag.Value or encoding.TextUnmarshaler are adapted using
+// FlagValue and TextValue respectively while map[string]T is handled by Map.
+// Types registered using RegisterEnum or RegisterEnumRange are handled by Enum.
 // It returns error if v is of unknown or unsupported type.
 func NewValue(v interface{}) (Value, error) {
+	// types registered as enums take precedence over any other interface they implement
+	if e, found, err := lookupEnum(v); found {
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
 	// if the underlying pointer type is one of the supported types then convert it to a
 	// ArgValue compatible type.
 	switch addr := v.(type) {
@@ -36,7 +52,40 @@ func NewValue(v interface{}) (Value, error) {
 		return NewFloat64(addr), nil
 	case *[]float64:
 		return NewFloat64List(addr), nil
+	case *map[string]string:
+		return NewStringMap(addr), nil
+	case *map[string]int:
+		return NewIntMap(addr), nil
+	case *net.IP:
+		return NewIP(addr), nil
+	case *[]net.IP:
+		return NewIPList(addr), nil
+	case *net.IPNet:
+		return NewIPNet(addr), nil
+	case *[]net.IPNet:
+		return NewIPNetList(addr), nil
+	case *net.HardwareAddr:
+		return NewHardwareAddr(addr), nil
+	case *[]net.HardwareAddr:
+		return NewHardwareAddrList(addr), nil
+	case *[]HostPort:
+		return NewHostPortList(addr), nil
+	case **url.URL:
+		return NewURL(addr), nil
+	case *[]*url.URL:
+		return NewURLList(addr), nil
+	case flag.Value:
+		return NewFlagValue(addr), nil
+	case encoding.TextUnmarshaler:
+		return NewTextValue(addr), nil
 	default:
+		if typ := reflect.TypeOf(v); typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Map {
+			m, err := NewMap(v)
+			if err != nil {
+				return nil, err
+			}
+			return m, nil
+		}
 		return nil, fmt.Errorf("unsupported type: %T", addr)
 	}
 }
@@ -221,3 +270,87 @@ func (fl *Float64List) Set(values ...string) error {
 func (fl *Float64List) Get() interface{} { return []float64(*fl) }
 
 func (fl *Float64List) String() string { return fmt.Sprint(*fl) }
+
+// destination returns the addressable reflect.Value in which v stores the
+// value it is set to, or an invalid reflect.Value if it is unknown.
+func destination(v Value) reflect.Value {
+	switch v := v.(type) {
+	case *Map:
+		return v.dest
+	case *Enum:
+		return v.dest
+	case *URL:
+		return reflect.ValueOf(v.dest).Elem()
+	case *URLList:
+		return reflect.ValueOf(v.dest).Elem()
+	case *Path:
+		return reflect.ValueOf(v.dest).Elem()
+	}
+	// all other values, including most user defined ones, are pointers to the
+	// value they store e.g. *Int
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem()
+	}
+	return reflect.Value{}
+}
+
+// copyValue returns a copy of v, which does not share the elements of v if it
+// is a slice or a map
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return c
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// snapshot saves the current value of v and returns a function which restores it
+func snapshot(v Value) func() {
+	switch v := v.(type) {
+	case *TextValue:
+		// the unmarshaled type may reuse its memory, e.g. big.Int, hence save
+		// it as text if possible
+		if m, ok := v.u.(encoding.TextMarshaler); ok {
+			if text, err := m.MarshalText(); err == nil {
+				return func() { v.u.UnmarshalText(text) }
+			}
+		}
+		return snapshotDestination(reflect.ValueOf(v.u))
+	case *FlagValue:
+		return snapshotDestination(reflect.ValueOf(v.v))
+	}
+	return snapshotDestination(reflect.ValueOf(v))
+}
+
+// snapshotDestination saves the destination of v, a pointer, and returns a
+// function which restores it
+func snapshotDestination(p reflect.Value) func() {
+	var dest reflect.Value
+	if v, ok := p.Interface().(Value); ok {
+		dest = destination(v)
+	} else if p.Kind() == reflect.Ptr && !p.IsNil() {
+		dest = p.Elem()
+	}
+	if !dest.IsValid() || !dest.CanSet() {
+		return func() {}
+	}
+	saved := copyValue(dest)
+	return func() { dest.Set(copyValue(saved)) }
+}
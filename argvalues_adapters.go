@@ -0,0 +1,110 @@
+package argparser
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// TextValue adapts any type implementing encoding.TextUnmarshaler to the Value
+// interface. If the type also implements encoding.TextMarshaler or fmt.Stringer
+// then that is used for String() and hence for displaying defaults in help.
+type TextValue struct {
+	u encoding.TextUnmarshaler
+}
+
+func NewTextValue(u encoding.TextUnmarshaler) *TextValue {
+	return &TextValue{u: u}
+}
+
+func (t *TextValue) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := t.u.UnmarshalText([]byte(values[0])); err != nil {
+		return formatParseError(values[0], fmt.Sprintf("%T", indirect(t.u)), err)
+	}
+	return nil
+}
+
+func (t *TextValue) Get() interface{} { return indirect(t.u) }
+
+// Clone returns a TextValue wrapping a copy of the wrapped value. It is only
+// independent of the original if the wrapped value is a pointer.
+func (t *TextValue) Clone() Value {
+	u, ok := clonePointer(t.u).(encoding.TextUnmarshaler)
+	if !ok {
+		u = t.u
+	}
+	return &TextValue{u: u}
+}
+
+func (t *TextValue) String() string {
+	switch u := t.u.(type) {
+	case encoding.TextMarshaler:
+		text, err := u.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	case fmt.Stringer:
+		return u.String()
+	}
+	return fmt.Sprint(indirect(t.u))
+}
+
+// FlagValue adapts a flag.Value from the standard library to the Value
+// interface. If the wrapped value also implements flag.Getter then its Get()
+// is used, otherwise Get() returns the value pointed to by the flag.Value.
+type FlagValue struct {
+	v flag.Value
+}
+
+func NewFlagValue(v flag.Value) *FlagValue {
+	return &FlagValue{v: v}
+}
+
+func (f *FlagValue) Set(values ...string) error {
+	if len(values) == 0 {
+		// like the flag package, boolean flags are set to true when no value is given
+		if bf, ok := f.v.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			values = append(values, "true")
+		} else {
+			return nil
+		}
+	}
+	if err := f.v.Set(values[0]); err != nil {
+		return formatParseError(values[0], fmt.Sprintf("%T", indirect(f.v)), err)
+	}
+	return nil
+}
+
+func (f *FlagValue) Get() interface{} {
+	if g, ok := f.v.(flag.Getter); ok {
+		return g.Get()
+	}
+	return indirect(f.v)
+}
+
+// Clone returns a FlagValue wrapping a copy of the wrapped value. It is only
+// independent of the original if the wrapped value is a pointer.
+func (f *FlagValue) Clone() Value {
+	v, ok := clonePointer(f.v).(flag.Value)
+	if !ok {
+		v = f.v
+	}
+	return &FlagValue{v: v}
+}
+
+func (f *FlagValue) String() string { return f.v.String() }
+
+// indirect returns the value pointed to by v if v is a non-nil pointer,
+// otherwise it returns v as is.
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
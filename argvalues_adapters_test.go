@@ -0,0 +1,141 @@
+package argparser
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// counterFlag implements flag.Value but not flag.Getter
+type counterFlag int
+
+func (c *counterFlag) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*c = counterFlag(n)
+	return nil
+}
+
+func (c *counterFlag) String() string { return strconv.Itoa(int(*c)) }
+
+// switchFlag implements flag.Getter and behaves like a boolean flag
+type switchFlag bool
+
+func (s *switchFlag) Set(v string) error {
+	b, err := strconv.ParseBool(v)
+	*s = switchFlag(b)
+	return err
+}
+
+func (s *switchFlag) String() string   { return strconv.FormatBool(bool(*s)) }
+func (s *switchFlag) Get() interface{} { return bool(*s) }
+func (s *switchFlag) IsBoolFlag() bool { return true }
+
+// point implements encoding.TextUnmarshaler but none of the interfaces
+// NewValue checks before it
+type point struct{ x, y int }
+
+func (p *point) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &p.x, &p.y)
+	return err
+}
+
+func TestTextValue(t *testing.T) {
+	var p point
+	textVal, err := NewValue(&p)
+	if err != nil {
+		t.Fatalf("Expected: NewValue(%T) should succeed, Got: %s", &p, err)
+	}
+	if _, ok := textVal.(*TextValue); !ok {
+		t.Fatalf("Expected: *TextValue, Got: %T", textVal)
+	}
+	if err := textVal.Set("3,4"); err != nil || p != (point{3, 4}) {
+		t.Errorf("Expected: {3 4}, Got: %v, %v for input \"3,4\"", p, err)
+	}
+	if err := textVal.Set("x"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"x\"")
+	}
+
+	var ip net.IP
+	val := NewTextValue(&ip)
+
+	if err := val.Set("10.0.0.1"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s' for input \"10.0.0.1\"", err)
+	}
+	if !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected: 10.0.0.1, Got: %v", ip)
+	}
+	if got, ok := val.Get().(net.IP); !ok || !got.Equal(ip) {
+		t.Errorf("Expected: Get() returns net.IP 10.0.0.1, Got: %#v", val.Get())
+	}
+	if val.String() != "10.0.0.1" {
+		t.Errorf("Expected: 10.0.0.1, Got: %v", val.String())
+	}
+
+	// Test invalid values
+	if err := val.Set("10.0.0.256"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"10.0.0.256\"")
+	}
+
+	var n big.Int
+	val = NewTextValue(&n)
+	if err := val.Set("123456789012345678901234567890"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s'", err)
+	}
+	if val.String() != "123456789012345678901234567890" {
+		t.Errorf("Expected: 123456789012345678901234567890, Got: %v", val.String())
+	}
+}
+
+func TestFlagValue(t *testing.T) {
+	var c counterFlag
+	val, err := NewValue(&c)
+	if err != nil {
+		t.Fatalf("Expected: NewValue(%T) should succeed, Got: %s", &c, err)
+	}
+	if err := val.Set("42"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s' for input \"42\"", err)
+	}
+	if c != 42 || val.String() != "42" {
+		t.Errorf("Expected: 42, Got: %v", c)
+	}
+	if got, ok := val.Get().(counterFlag); !ok || got != 42 {
+		t.Errorf("Expected: Get() returns counterFlag(42), Got: %#v", val.Get())
+	}
+	if err := val.Set("x"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"x\"")
+	}
+
+	var s switchFlag
+	val = NewFlagValue(&s)
+	if err := val.Set(); err != nil || !s {
+		t.Errorf("Expected: Set() sets a bool flag to true, Got: %v, %v", s, err)
+	}
+	if got, ok := val.Get().(bool); !ok || !got {
+		t.Errorf("Expected: Get() returns true via flag.Getter, Got: %#v", val.Get())
+	}
+}
+
+func TestNewArgSetFromAdaptedTypes(t *testing.T) {
+	args := struct {
+		Addr  net.IP      `argparser:"help=listen address"`
+		Count counterFlag `argparser:"help=a counter"`
+	}{
+		Addr: net.ParseIP("127.0.0.1"),
+	}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatalf("testing: NewArgSetFrom(%#v); expected: no error; got: %s", args, err)
+	}
+	argset.ArgList = []string{"--addr", "::1", "--count", "3"}
+	if err := argset.Parse(); err != nil {
+		t.Fatalf("testing: Parse(); expected: no error; got: %s", err)
+	}
+	if !args.Addr.Equal(net.IPv6loopback) || args.Count != 3 {
+		t.Errorf("Expected: Addr=::1, Count=3; Got: %+v", args)
+	}
+}
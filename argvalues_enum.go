@@ -0,0 +1,220 @@
+package argparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Enum represents a value of an integer or string kind type, e.g. 'type Mode int',
+// which can only be set to one of a fixed set of names. Get() returns the
+// constant of the destination's type mapped to the given name.
+type Enum struct {
+	dest   reflect.Value
+	names  []string
+	values map[string]reflect.Value
+}
+
+func isEnumKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.String:
+		return true
+	}
+	return false
+}
+
+// enumDest verifies that p is a non-nil pointer to an integer or string kind type
+// and returns the value pointed to by it
+func enumDest(p interface{}) (reflect.Value, error) {
+	typ := reflect.TypeOf(p)
+	if typ == nil || typ.Kind() != reflect.Ptr || !isEnumKind(typ.Elem().Kind()) {
+		return reflect.Value{}, fmt.Errorf("unsupported enum type: %T", p)
+	}
+	if reflect.ValueOf(p).IsNil() {
+		return reflect.Value{}, fmt.Errorf("nil pointer of type: %T", p)
+	}
+	return reflect.ValueOf(p).Elem(), nil
+}
+
+// NewEnum returns an Enum for p, a pointer to an integer or string kind type,
+// which accepts the names in table. Every value in table must be convertible
+// to the type pointed to by p.
+func NewEnum(p interface{}, table map[string]interface{}) (*Enum, error) {
+	dest, err := enumDest(p)
+	if err != nil {
+		return nil, err
+	}
+	return newEnumFromTable(dest, table)
+}
+
+func newEnumFromTable(dest reflect.Value, table map[string]interface{}) (*Enum, error) {
+	if len(table) == 0 {
+		return nil, fmt.Errorf("enum table cannot be empty")
+	}
+	e := &Enum{dest: dest, values: make(map[string]reflect.Value, len(table))}
+	for name, v := range table {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || !isEnumKind(rv.Kind()) || !rv.Type().ConvertibleTo(dest.Type()) ||
+			(rv.Kind() == reflect.String) != (dest.Kind() == reflect.String) {
+			return nil, fmt.Errorf("enum value for '%s' of type %T is not convertible to %s", name, v, dest.Type())
+		}
+		e.names = append(e.names, name)
+		e.values[name] = rv.Convert(dest.Type())
+	}
+	// sort names by their values: numerically for integer types, which lists
+	// constants declared using iota in declaration order, and alphabetically
+	// for string types
+	sort.Slice(e.names, func(i, j int) bool {
+		vi, vj := e.values[e.names[i]], e.values[e.names[j]]
+		switch dest.Kind() {
+		case reflect.String:
+			return vi.String() < vj.String()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if vi.Uint() != vj.Uint() {
+				return vi.Uint() < vj.Uint()
+			}
+		default:
+			if vi.Int() != vj.Int() {
+				return vi.Int() < vj.Int()
+			}
+		}
+		return e.names[i] < e.names[j]
+	})
+	return e, nil
+}
+
+// NewEnumRange returns an Enum for p, a pointer to an integer kind type which
+// implements fmt.Stringer, by calling String() on every value from min to max
+// inclusive and accepting the resulting names.
+func NewEnumRange(p interface{}, min, max int64) (*Enum, error) {
+	dest, err := enumDest(p)
+	if err != nil {
+		return nil, err
+	}
+	return newEnumFromRange(dest, min, max)
+}
+
+func newEnumFromRange(dest reflect.Value, min, max int64) (*Enum, error) {
+	if dest.Kind() == reflect.String {
+		return nil, fmt.Errorf("enum range requires an integer type, got %s", dest.Type())
+	}
+	if _, ok := dest.Addr().Interface().(fmt.Stringer); !ok {
+		if _, ok := dest.Interface().(fmt.Stringer); !ok {
+			return nil, fmt.Errorf("enum range requires %s to implement fmt.Stringer", dest.Type())
+		}
+	}
+	if min > max {
+		return nil, fmt.Errorf("invalid enum range [%d, %d]", min, max)
+	}
+	table := make(map[string]interface{})
+	for i := min; ; i++ {
+		v := reflect.New(dest.Type())
+		v.Elem().Set(reflect.ValueOf(i).Convert(dest.Type()))
+		name := v.Interface().(fmt.Stringer).String()
+		if _, dup := table[name]; dup {
+			return nil, fmt.Errorf("enum name '%s' is used by more than one value of %s", name, dest.Type())
+		}
+		table[name] = v.Elem().Interface()
+		// checked here rather than in the loop condition since i++ would
+		// overflow if max is math.MaxInt64
+		if i == max {
+			break
+		}
+	}
+	return newEnumFromTable(dest, table)
+}
+
+// Choices returns the accepted names in order of their values
+func (e *Enum) Choices() []string {
+	return append([]string(nil), e.names...)
+}
+
+func (e *Enum) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, ok := e.values[values[0]]
+	if !ok {
+		return formatParseError(values[0], e.dest.Type().String(), fmt.Errorf("must be one of: %s", strings.Join(e.names, ", ")))
+	}
+	e.dest.Set(v)
+	return nil
+}
+
+func (e *Enum) Get() interface{} { return e.dest.Interface() }
+
+// Clone returns an Enum with the same names storing the current value in a
+// new variable
+func (e *Enum) Clone() Value {
+	dest := reflect.New(e.dest.Type()).Elem()
+	dest.Set(e.dest)
+	return &Enum{dest: dest, names: e.names, values: e.values}
+}
+
+// String returns the name mapped to the current value or, if there is none,
+// the current value formatted with fmt.Sprint
+func (e *Enum) String() string {
+	for _, name := range e.names {
+		if e.values[name].Interface() == e.dest.Interface() {
+			return name
+		}
+	}
+	return fmt.Sprint(e.dest.Interface())
+}
+
+// enumRegistry holds enum definitions registered using RegisterEnum and
+// RegisterEnumRange, keyed by type
+var enumRegistry = struct {
+	sync.RWMutex
+	defs map[reflect.Type]func(reflect.Value) (*Enum, error)
+}{defs: make(map[reflect.Type]func(reflect.Value) (*Enum, error))}
+
+func registerEnum(typ reflect.Type, newEnum func(reflect.Value) (*Enum, error)) error {
+	if typ == nil || !isEnumKind(typ.Kind()) {
+		return fmt.Errorf("unsupported enum type: %v", typ)
+	}
+	// verify the definition once so that errors are reported at registration time
+	if _, err := newEnum(reflect.New(typ).Elem()); err != nil {
+		return err
+	}
+	enumRegistry.Lock()
+	defer enumRegistry.Unlock()
+	enumRegistry.defs[typ] = newEnum
+	return nil
+}
+
+// RegisterEnum registers table as the enum definition for the type of zero,
+// after which NewValue, and hence NewArgSetFrom, returns an Enum for pointers
+// to that type.
+func RegisterEnum(zero interface{}, table map[string]interface{}) error {
+	return registerEnum(reflect.TypeOf(zero), func(dest reflect.Value) (*Enum, error) {
+		return newEnumFromTable(dest, table)
+	})
+}
+
+// RegisterEnumRange is like RegisterEnum but discovers the names using the
+// String() method of the type of zero as done by NewEnumRange.
+func RegisterEnumRange(zero interface{}, min, max int64) error {
+	return registerEnum(reflect.TypeOf(zero), func(dest reflect.Value) (*Enum, error) {
+		return newEnumFromRange(dest, min, max)
+	})
+}
+
+// lookupEnum returns an Enum for p if the type pointed to by p has been registered
+func lookupEnum(p interface{}) (*Enum, bool, error) {
+	typ := reflect.TypeOf(p)
+	if typ == nil || typ.Kind() != reflect.Ptr || reflect.ValueOf(p).IsNil() {
+		return nil, false, nil
+	}
+	enumRegistry.RLock()
+	newEnum, found := enumRegistry.defs[typ.Elem()]
+	enumRegistry.RUnlock()
+	if !found {
+		return nil, false, nil
+	}
+	e, err := newEnum(reflect.ValueOf(p).Elem())
+	return e, true, err
+}
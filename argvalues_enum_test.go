@@ -0,0 +1,138 @@
+package argparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type testMode int
+
+const (
+	testModeFast testMode = iota
+	testModeSafe
+	testModeDebug
+)
+
+func (m testMode) String() string {
+	switch m {
+	case testModeFast:
+		return "fast"
+	case testModeSafe:
+		return "safe"
+	case testModeDebug:
+		return "debug"
+	}
+	return "unknown"
+}
+
+type testColor string
+
+type testLevel uint8
+
+func TestEnumFromTable(t *testing.T) {
+	var testVar testColor
+	arg, err := NewEnum(&testVar, map[string]interface{}{"red": "r", "green": testColor("g")})
+	if err != nil {
+		t.Fatalf("Expected: no error, Got: %s", err)
+	}
+	if err := arg.Set("green"); err != nil || testVar != "g" {
+		t.Errorf("Expected: g, Got: %v, %v", testVar, err)
+	}
+	if got, ok := arg.Get().(testColor); !ok || got != "g" {
+		t.Errorf("Expected: Get() returns testColor(\"g\"), Got: %#v", arg.Get())
+	}
+	if arg.String() != "green" {
+		t.Errorf("Expected: green, Got: %v", arg.String())
+	}
+	if err := arg.Set("blue"); err == nil || !strings.Contains(err.Error(), "green, red") {
+		t.Errorf("Expected: error listing the choices, Got: %v", err)
+	}
+
+	var level testLevel
+	arg, err = NewEnum(&level, map[string]interface{}{"low": 1, "high": 9})
+	if err != nil {
+		t.Fatalf("Expected: no error, Got: %s", err)
+	}
+	if err := arg.Set("high"); err != nil || level != 9 {
+		t.Errorf("Expected: 9, Got: %v, %v", level, err)
+	}
+	if c := arg.Choices(); len(c) != 2 || c[0] != "low" || c[1] != "high" {
+		t.Errorf("Expected: choices ordered by value [low high], Got: %v", c)
+	}
+
+	invalid := []struct {
+		p     interface{}
+		table map[string]interface{}
+	}{
+		{new(float64), map[string]interface{}{"a": 1.0}},
+		{new(testMode), map[string]interface{}{}},
+		{new(testMode), map[string]interface{}{"a": "x"}},
+		{new(testColor), map[string]interface{}{"a": 1}},
+		{(*testMode)(nil), map[string]interface{}{"a": 1}},
+	}
+	for _, val := range invalid {
+		if _, err := NewEnum(val.p, val.table); err == nil {
+			t.Errorf("Expected: NewEnum(%T, %v) should fail, Got: no error", val.p, val.table)
+		}
+	}
+}
+
+func TestEnumFromRange(t *testing.T) {
+	var testVar testMode
+	arg, err := NewEnumRange(&testVar, 0, 2)
+	if err != nil {
+		t.Fatalf("Expected: no error, Got: %s", err)
+	}
+	if c := arg.Choices(); strings.Join(c, ",") != "fast,safe,debug" {
+		t.Errorf("Expected: [fast safe debug], Got: %v", c)
+	}
+	if err := arg.Set("safe"); err != nil || testVar != testModeSafe {
+		t.Errorf("Expected: testModeSafe, Got: %v, %v", testVar, err)
+	}
+	if _, err := NewEnumRange(&testVar, 0, 4); err == nil {
+		t.Errorf("Expected: error since 3 and 4 are both named 'unknown', Got: no error")
+	}
+	if arg, err := NewEnumRange(&testVar, math.MaxInt64, math.MaxInt64); err != nil || strings.Join(arg.Choices(), ",") != "unknown" {
+		t.Errorf("Expected: [unknown] for range ending at math.MaxInt64, Got: %v", err)
+	}
+	var color testColor
+	if _, err := NewEnumRange(&color, 0, 1); err == nil {
+		t.Errorf("Expected: error for string kind type, Got: no error")
+	}
+}
+
+func TestRegisteredEnum(t *testing.T) {
+	if err := RegisterEnumRange(testMode(0), 0, 2); err != nil {
+		t.Fatalf("Expected: no error, Got: %s", err)
+	}
+
+	args := struct {
+		Mode testMode `argparser:"help=processing mode"`
+	}{
+		Mode: testModeSafe,
+	}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.usage()
+	if !strings.Contains(out.String(), "fast, safe, debug") || !strings.Contains(out.String(), "(Default: safe)") {
+		t.Errorf("testing: usage(); expected: choices and default listed; got: %s", out.String())
+	}
+
+	argset.ArgList = []string{"--mode", "debug"}
+	if err := argset.Parse(); err != nil || args.Mode != testModeDebug {
+		t.Errorf("testing: Parse(); expected: Mode==testModeDebug; got: %v, %v", args.Mode, err)
+	}
+	argset.ArgList = []string{"--mode", "slow"}
+	if err := argset.Parse(); err == nil {
+		t.Errorf("testing: Parse() with invalid enum name; expected: error; got: no error")
+	}
+
+	if err := RegisterEnum(1.5, map[string]interface{}{"a": 1.5}); err == nil {
+		t.Errorf("Expected: RegisterEnum with float type should fail, Got: no error")
+	}
+}
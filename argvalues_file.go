@@ -0,0 +1,312 @@
+package argparser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stdStream is the file name which stands for standard input or output
+const stdStream string = "-"
+
+// PathCheck is a set of expectations which a Path verifies whenever it is set.
+// Checks can be combined using '|' e.g. PathMustExist|PathIsDir.
+type PathCheck int
+
+const (
+	// PathMustExist requires the path to exist
+	PathMustExist PathCheck = 1 << iota
+	// PathMustNotExist requires the path to not exist
+	PathMustNotExist
+	// PathIsDir requires the path to exist and be a directory
+	PathIsDir
+	// PathIsFile requires the path to exist and be a regular file
+	PathIsFile
+	// PathReadable requires the path to exist and be readable
+	PathReadable
+)
+
+// Path represents a file system path stored in a string. The path is not
+// opened, only the checks given in Checks are verified when it is set.
+type Path struct {
+	dest   *string
+	Checks PathCheck
+}
+
+func NewPath(p *string, checks PathCheck) *Path {
+	return &Path{dest: p, Checks: checks}
+}
+
+// pathError returns an error of the form '<path>: <reason>' for err, stripping
+// the operation and path added by the os package
+func pathError(path string, err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return fmt.Errorf("%s: %s", path, err)
+}
+
+func (p *Path) check(path string) error {
+	if p.Checks == 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if p.Checks&PathMustNotExist != 0 {
+		if err == nil {
+			return pathError(path, os.ErrExist)
+		}
+		if !os.IsNotExist(err) {
+			return pathError(path, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return pathError(path, err)
+	}
+	if p.Checks&PathIsDir != 0 && !info.IsDir() {
+		return pathError(path, errors.New("not a directory"))
+	}
+	if p.Checks&PathIsFile != 0 && !info.Mode().IsRegular() {
+		return pathError(path, errors.New("not a regular file"))
+	}
+	if p.Checks&PathReadable != 0 {
+		f, err := os.Open(path)
+		if err != nil {
+			return pathError(path, err)
+		}
+		f.Close()
+	}
+	return nil
+}
+
+func (p *Path) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := p.check(values[0]); err != nil {
+		return err
+	}
+	*p.dest = values[0]
+	return nil
+}
+
+func (p *Path) Get() interface{} { return *p.dest }
+
+// Metavar returns PATH, the placeholder shown for the value in help
+func (p *Path) Metavar() string { return "PATH" }
+
+// Clone returns a Path with the same checks storing the current value in a new
+// variable
+func (p *Path) Clone() Value {
+	dest := new(string)
+	*dest = *p.dest
+	return &Path{dest: dest, Checks: p.Checks}
+}
+
+func (p *Path) String() string { return *p.dest }
+
+// InputFile represents a file opened for reading, the name '-' stands for
+// standard input. Setting the value only verifies that the file exists and, if
+// it is a regular file, that it can be read, so that errors are reported while
+// parsing. The file is opened on first Read or by calling Open explicitly,
+// hence parsing, e.g. by ParseSnapshot, never leaves files open.
+// *InputFile implements Value and io.ReadCloser.
+type InputFile struct {
+	Name string
+	file *os.File
+}
+
+// Open opens the file named by Name if it is not already open. An empty
+// Name is treated as '-'.
+func (f *InputFile) Open() error {
+	if f.file != nil {
+		return nil
+	}
+	if f.Name == "" || f.Name == stdStream {
+		f.file = os.Stdin
+		return nil
+	}
+	file, err := os.Open(f.Name)
+	if err != nil {
+		return pathError(f.Name, err)
+	}
+	f.file = file
+	return nil
+}
+
+// checkInput returns error if the file named name does not exist or is a
+// regular file which cannot be read. Other files, like named pipes, are not
+// opened since that may block.
+func checkInput(name string) error {
+	if name == stdStream {
+		return nil
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return pathError(name, err)
+	}
+	if info.IsDir() {
+		return pathError(name, errors.New("is a directory"))
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return pathError(name, err)
+	}
+	return file.Close()
+}
+
+func (f *InputFile) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := checkInput(values[0]); err != nil {
+		return err
+	}
+	f.Close()
+	f.Name = values[0]
+	return nil
+}
+
+func (f *InputFile) Get() interface{} { return f }
+
+// Metavar returns FILE, the placeholder shown for the value in help
+func (f *InputFile) Metavar() string { return "FILE" }
+
+// Clone returns an InputFile with the same name which has not been opened
+func (f *InputFile) Clone() Value { return &InputFile{Name: f.Name} }
+
+func (f *InputFile) String() string { return f.Name }
+
+// File returns the underlying file or nil if it has not been opened yet
+func (f *InputFile) File() *os.File { return f.file }
+
+func (f *InputFile) Read(p []byte) (int, error) {
+	if err := f.Open(); err != nil {
+		return 0, err
+	}
+	return f.file.Read(p)
+}
+
+// Close closes the underlying file. Standard input is never closed.
+func (f *InputFile) Close() error {
+	file := f.file
+	f.file = nil
+	if file == nil || file == os.Stdin {
+		return nil
+	}
+	return file.Close()
+}
+
+// OutputFile represents a file opened for writing, the name '-' stands for
+// standard output. Setting the value only verifies that the file can be
+// created, i.e. that its directory exists and it is not a directory itself.
+// The file is created or truncated, or appended to if Append is true, on first
+// Write or by calling Open explicitly, hence it is left untouched if parsing
+// fails. *OutputFile implements Value and io.WriteCloser.
+type OutputFile struct {
+	Name   string
+	Append bool
+	// Perm is used when creating the file, 0666 (before umask) if zero
+	Perm os.FileMode
+	file *os.File
+}
+
+// Open opens the file named by Name if it is not already open. An empty
+// Name is treated as '-'.
+func (f *OutputFile) Open() error {
+	if f.file != nil {
+		return nil
+	}
+	if f.Name == "" || f.Name == stdStream {
+		f.file = os.Stdout
+		return nil
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if f.Append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	perm := f.Perm
+	if perm == 0 {
+		perm = 0666
+	}
+	file, err := os.OpenFile(f.Name, flag, perm)
+	if err != nil {
+		return pathError(f.Name, err)
+	}
+	f.file = file
+	return nil
+}
+
+// checkOutput returns error if a file named name could not be created without
+// touching the file system
+func checkOutput(name string) error {
+	if name == stdStream {
+		return nil
+	}
+	info, err := os.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return pathError(name, errors.New("is a directory"))
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return pathError(name, err)
+	}
+	info, err = os.Stat(filepath.Dir(name))
+	if err != nil {
+		return pathError(name, err)
+	}
+	if !info.IsDir() {
+		return pathError(name, errors.New("not a directory"))
+	}
+	return nil
+}
+
+func (f *OutputFile) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := checkOutput(values[0]); err != nil {
+		return err
+	}
+	f.Close()
+	f.Name = values[0]
+	return nil
+}
+
+func (f *OutputFile) Get() interface{} { return f }
+
+// Metavar returns FILE, the placeholder shown for the value in help
+func (f *OutputFile) Metavar() string { return "FILE" }
+
+// Clone returns an OutputFile with the same settings which has not been opened
+func (f *OutputFile) Clone() Value {
+	return &OutputFile{Name: f.Name, Append: f.Append, Perm: f.Perm}
+}
+
+func (f *OutputFile) String() string { return f.Name }
+
+// File returns the underlying file or nil if it has not been opened yet
+func (f *OutputFile) File() *os.File { return f.file }
+
+func (f *OutputFile) Write(p []byte) (int, error) {
+	if err := f.Open(); err != nil {
+		return 0, err
+	}
+	return f.file.Write(p)
+}
+
+// Close closes the underlying file. Standard output is never closed.
+func (f *OutputFile) Close() error {
+	file := f.file
+	f.file = nil
+	if file == nil || file == os.Stdout {
+		return nil
+	}
+	return file.Close()
+}
@@ -0,0 +1,183 @@
+package argparser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	data := []struct {
+		checks PathCheck
+		valid  []string
+		errors []string
+	}{
+		{0, []string{dir, file, missing}, nil},
+		{PathMustExist, []string{dir, file}, []string{missing}},
+		{PathMustNotExist, []string{missing}, []string{dir, file}},
+		{PathIsDir, []string{dir}, []string{file, missing}},
+		{PathIsFile, []string{file}, []string{dir, missing}},
+		{PathReadable | PathIsFile, []string{file}, []string{dir, missing}},
+	}
+	for _, val := range data {
+		var testVar string
+		arg := NewPath(&testVar, val.checks)
+		for _, input := range val.valid {
+			if err := arg.Set(input); err != nil {
+				t.Errorf("Expected: no error for checks %b, Got: error '%s' for input \"%s\"", val.checks, err, input)
+			}
+			if testVar != input || arg.String() != input {
+				t.Errorf("Expected: %v, Got: %v", input, testVar)
+			}
+		}
+		for _, input := range val.errors {
+			if err := arg.Set(input); err == nil {
+				t.Errorf("Expected: error for checks %b, Got: no error for input \"%s\"", val.checks, input)
+			}
+		}
+	}
+}
+
+func TestInputOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argparser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	inPath := filepath.Join(dir, "in.txt")
+	outPath := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(inPath, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := struct {
+		Input  InputFile  `argparser:"help=input file"`
+		Output OutputFile `argparser:"help=output file"`
+	}{}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer argset.Close()
+
+	argset.ArgList = []string{"--input", inPath, "--output", outPath}
+	if err := argset.Parse(); err != nil {
+		t.Fatalf("testing: Parse(); expected: no error; got: %s", err)
+	}
+	data, err := ioutil.ReadAll(&args.Input)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected: hello, Got: %q, %v", data, err)
+	}
+	if _, err := args.Output.Write(data); err != nil {
+		t.Errorf("Expected: no error, Got: %s", err)
+	}
+	if err := argset.Close(); err != nil {
+		t.Errorf("testing: Close(); expected: no error; got: %s", err)
+	}
+	if args.Input.File() != nil || args.Output.File() != nil {
+		t.Errorf("testing: Close(); expected: all files closed")
+	}
+	if written, _ := ioutil.ReadFile(outPath); string(written) != "hello" {
+		t.Errorf("Expected: hello, Got: %q", written)
+	}
+
+	// Test that a missing file is reported while parsing
+	args.Input = InputFile{}
+	argset.ArgList = []string{"--input", filepath.Join(dir, "missing")}
+	if err := argset.Parse(); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("testing: Parse() with missing input file; expected: 'no such file' error; got: %v", err)
+	}
+
+	// Test that the output file is neither opened nor truncated by parsing
+	if err := ioutil.WriteFile(outPath, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args.Output = OutputFile{}
+	argset.ArgList = []string{"--output", outPath, "--input", filepath.Join(dir, "missing")}
+	if err := argset.Parse(); err == nil {
+		t.Errorf("testing: Parse() with missing input file; expected: error; got: nil")
+	}
+	argset.ArgList = []string{"--output", outPath}
+	if err := argset.Parse(); err != nil || args.Output.File() != nil {
+		t.Errorf("testing: Parse() with output file; expected: file not opened; got: %v, %v", args.Output.File(), err)
+	}
+	if kept, _ := ioutil.ReadFile(outPath); string(kept) != "keep" {
+		t.Errorf("testing: Parse() with output file; expected: file untouched; got: %q", kept)
+	}
+	for _, name := range []string{dir, filepath.Join(dir, "missing", "out.txt")} {
+		if err := args.Output.Set(name); err == nil {
+			t.Errorf("Expected: error, Got: no error for output file \"%s\"", name)
+		}
+	}
+
+	// Test '-' as standard input/output
+	var in InputFile
+	if err := in.Set("-"); err != nil || in.Open() != nil || in.File() != os.Stdin {
+		t.Errorf("Expected: '-' opens standard input, Got: %v, %v", in.File(), err)
+	}
+	in.Close()
+	var out OutputFile
+	if err := out.Set("-"); err != nil || out.Open() != nil || out.File() != os.Stdout {
+		t.Errorf("Expected: '-' opens standard output, Got: %v, %v", out.File(), err)
+	}
+	out.Close()
+}
+
+func TestArgSetOnClose(t *testing.T) {
+	argset := NewArgSet()
+	var order []int
+	argset.OnClose(func() error { order = append(order, 1); return nil })
+	argset.OnClose(func() error { order = append(order, 2); return nil })
+	if err := argset.Close(); err != nil || len(order) != 2 || order[0] != 2 {
+		t.Errorf("testing: Close(); expected: hooks called in reverse order; got: %v, %v", order, err)
+	}
+}
+
+// closeOnce is a Value which fails when closed more than once, counting every
+// call in closed
+type closeOnce struct{ closed *int }
+
+func (c closeOnce) Set(...string) error { return nil }
+func (c closeOnce) Get() interface{}    { return *c.closed }
+func (c closeOnce) String() string      { return "" }
+
+func (c closeOnce) Close() error {
+	*c.closed++
+	if *c.closed > 1 {
+		return fmt.Errorf("closed %d times", *c.closed)
+	}
+	return nil
+}
+
+func TestArgSetClosesValuesOnce(t *testing.T) {
+	var closed int
+	argset := NewArgSet()
+	arg := NewOptArg(closeOnce{closed: &closed}, "")
+	arg.SetShort("f")
+	arg.AddAlias("file", "input")
+	argset.Add("in", arg)
+
+	// Test that a value is closed once rather than once per name
+	argset.Reset()
+	if closed != 1 {
+		t.Errorf("testing: Reset() with short name and aliases; expected: value closed once; got: %d times", closed)
+	}
+	closed = 0
+	if err := argset.Close(); err != nil || closed != 1 {
+		t.Errorf("testing: Close() with short name and aliases; expected: value closed once; got: %d times, %v", closed, err)
+	}
+}
@@ -0,0 +1,234 @@
+package argparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	mapPairSep     rune = ','
+	mapKeyValueSep rune = '='
+	escapeRune     rune = '\\'
+)
+
+// DuplicateKeyPolicy decides what a Map does when the same key is given more
+// than once, either within one value or across repeated options.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyOverwrite keeps the value given last
+	DuplicateKeyOverwrite DuplicateKeyPolicy = iota
+	// DuplicateKeyKeepFirst keeps the value given first
+	DuplicateKeyKeepFirst
+	// DuplicateKeyError rejects the duplicate key with an error
+	DuplicateKeyError
+)
+
+// duplicateKeyPolicies maps the values of the 'onduplicate' struct tag key to
+// the respective policy
+var duplicateKeyPolicies = map[string]DuplicateKeyPolicy{
+	"overwrite": DuplicateKeyOverwrite,
+	"keepfirst": DuplicateKeyKeepFirst,
+	"error":     DuplicateKeyError,
+}
+
+// RepeatableValue is implemented by values which may be given more than once
+// on the command line. The first occurrence of the option calls Set while
+// every later occurrence calls Append with the new values.
+type RepeatableValue interface {
+	Value
+	Append(...string) error
+}
+
+// Map represents a map[string]T value given as key=value pairs, where T is any
+// type supported by NewValue. Pairs can be given as separate values and/or
+// separated by ',' within one value. A ',' or '=' which is part of a key or
+// value must be escaped with '\' and '\\' stands for a single backslash, any
+// other '\' is kept as is. The policy for duplicate keys can be set with the
+// 'onduplicate' struct tag key. Map implements RepeatableValue hence
+// '--label env=prod --label team=core' is equivalent to '--label env=prod,team=core'.
+type Map struct {
+	dest        reflect.Value
+	OnDuplicate DuplicateKeyPolicy
+}
+
+// NewMap returns a Map for p which must be a pointer to map[string]T.
+// It returns error if p is of any other type or T is not supported by NewValue.
+func NewMap(p interface{}) (*Map, error) {
+	typ := reflect.TypeOf(p)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Map || typ.Elem().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("unsupported type: %T", p)
+	}
+	if reflect.ValueOf(p).IsNil() {
+		return nil, fmt.Errorf("nil pointer of type: %T", p)
+	}
+	if _, err := NewValue(reflect.New(typ.Elem().Elem()).Interface()); err != nil {
+		return nil, fmt.Errorf("unsupported map element type: %s", err)
+	}
+	return &Map{dest: reflect.ValueOf(p).Elem()}, nil
+}
+
+func NewStringMap(p *map[string]string) *Map {
+	return &Map{dest: reflect.ValueOf(p).Elem()}
+}
+
+func NewIntMap(p *map[string]int) *Map {
+	return &Map{dest: reflect.ValueOf(p).Elem()}
+}
+
+// Set replaces the current map with the key=value pairs given in values.
+func (m *Map) Set(values ...string) error {
+	newMap := reflect.MakeMap(m.dest.Type())
+	if err := m.setPairs(newMap, values); err != nil {
+		return err
+	}
+	m.dest.Set(newMap)
+	return nil
+}
+
+// Append adds the key=value pairs given in values to the current map.
+func (m *Map) Append(values ...string) error {
+	// build a new map instead of modifying the current one in place so that
+	// maps previously returned by Get() are left untouched
+	newMap := reflect.MakeMap(m.dest.Type())
+	iter := m.dest.MapRange()
+	for iter.Next() {
+		newMap.SetMapIndex(iter.Key(), iter.Value())
+	}
+	if err := m.setPairs(newMap, values); err != nil {
+		return err
+	}
+	m.dest.Set(newMap)
+	return nil
+}
+
+func (m *Map) setPairs(dest reflect.Value, values []string) error {
+	typ := m.dest.Type()
+	for _, val := range values {
+		for _, pair := range splitEscaped(val, mapPairSep) {
+			parts := splitEscapedN(pair, mapKeyValueSep, 2)
+			if len(parts) != 2 {
+				return formatParseError(pair, typ.String(), fmt.Errorf("expected key%cvalue", mapKeyValueSep))
+			}
+			key := reflect.ValueOf(unescape(parts[0], mapSpecials)).Convert(typ.Key())
+			if dest.MapIndex(key).IsValid() {
+				switch m.OnDuplicate {
+				case DuplicateKeyKeepFirst:
+					continue
+				case DuplicateKeyError:
+					return formatParseError(pair, typ.String(), fmt.Errorf("duplicate key '%s'", key))
+				}
+			}
+			elem := reflect.New(typ.Elem())
+			elemValue, err := NewValue(elem.Interface())
+			if err != nil {
+				return err
+			}
+			if err := elemValue.Set(unescape(parts[1], mapSpecials)); err != nil {
+				return err
+			}
+			dest.SetMapIndex(key, elem.Elem())
+		}
+	}
+	return nil
+}
+
+func (m *Map) Get() interface{} { return m.dest.Interface() }
+
+// Metavar returns KEY=VALUE, showing the format of every pair in help
+func (m *Map) Metavar() string { return "KEY=VALUE" }
+
+// Clone returns a Map with the same policy storing a copy of the current map
+// in a new variable
+func (m *Map) Clone() Value {
+	dest := reflect.New(m.dest.Type()).Elem()
+	dest.Set(copyValue(m.dest))
+	return &Map{dest: dest, OnDuplicate: m.OnDuplicate}
+}
+
+// String returns the pairs sorted by key in the same format accepted by Set.
+func (m *Map) String() string {
+	keys := make([]string, 0, m.dest.Len())
+	for _, k := range m.dest.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		elem := reflect.New(m.dest.Type().Elem())
+		elem.Elem().Set(m.dest.MapIndex(reflect.ValueOf(k).Convert(m.dest.Type().Key())))
+		elemValue, _ := NewValue(elem.Interface())
+		pairs[i] = fmt.Sprintf("%s%c%s", escape(k), mapKeyValueSep, escape(elemValue.String()))
+	}
+	return strings.Join(pairs, string(mapPairSep))
+}
+
+// mapSpecials are the runes which must be escaped within a key or a value of
+// a Map
+const mapSpecials = string(mapPairSep) + string(mapKeyValueSep)
+
+// isEscape reports whether s[i] is a '\' escaping the next character. This is
+// only the case if the next character is a '\' or one of specials, any other
+// '\', including a trailing one, stands for itself. Struct tags and Map values
+// share this rule.
+func isEscape(s string, i int, specials string) bool {
+	return s[i] == byte(escapeRune) && i+1 < len(s) &&
+		(s[i+1] == byte(escapeRune) || strings.IndexByte(specials, s[i+1]) >= 0)
+}
+
+// splitEscaped splits src around each sep not escaped by '\'. Unlike lexTags
+// escape sequences are retained in the parts so that they can be split further.
+// Empty parts are dropped.
+func splitEscaped(src string, sep rune) []string {
+	parts := make([]string, 0)
+	for _, part := range splitEscapedN(src, sep, -1) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// splitEscapedN is like splitEscaped but returns at most n parts, the last
+// part being the unsplit remainder, and does not drop empty parts.
+func splitEscapedN(src string, sep rune, n int) []string {
+	parts := make([]string, 0)
+	begin := 0
+	for i := 0; i < len(src); i++ {
+		switch {
+		case isEscape(src, i, mapSpecials):
+			i++
+		case src[i] == byte(sep) && (n < 0 || len(parts) < n-1):
+			parts = append(parts, src[begin:i])
+			begin = i + 1
+		}
+	}
+	return append(parts, src[begin:])
+}
+
+// unescape removes the '\' from every escape sequence in s, see isEscape
+func unescape(s string, specials string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if isEscape(s, i, specials) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// escape is the inverse of unescape for the separators used by Map
+func escape(s string) string {
+	b := &strings.Builder{}
+	for _, curRune := range s {
+		if curRune == escapeRune || curRune == mapPairSep || curRune == mapKeyValueSep {
+			b.WriteRune(escapeRune)
+		}
+		b.WriteRune(curRune)
+	}
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package argparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapValueCreation(t *testing.T) {
+	supported := []interface{}{
+		new(map[string]string),
+		new(map[string]int),
+		new(map[string]float64),
+		new(map[string]bool),
+	}
+	for _, val := range supported {
+		if _, err := NewValue(val); err != nil {
+			t.Errorf("Expected: NewValue(%T) should succeed, Got: %s", val, err)
+		}
+	}
+
+	unsupported := []interface{}{
+		new(map[int]string),
+		new(map[string]struct{}),
+		map[string]string{},
+	}
+	for _, val := range unsupported {
+		if _, err := NewMap(val); err == nil {
+			t.Errorf("Expected: NewMap(%T) should fail, Got: no error", val)
+		}
+	}
+}
+
+func TestMapType(t *testing.T) {
+	var testVar map[string]string
+	arg := NewStringMap(&testVar)
+
+	data := []struct {
+		input    []string
+		expected map[string]string
+	}{
+		{[]string{"env=prod"}, map[string]string{"env": "prod"}},
+		{[]string{"env=prod,team=core"}, map[string]string{"env": "prod", "team": "core"}},
+		{[]string{"env=prod", "team=core"}, map[string]string{"env": "prod", "team": "core"}},
+		{[]string{"q=a=b"}, map[string]string{"q": "a=b"}},
+		{[]string{`a\,b=c\,d\=e`}, map[string]string{"a,b": "c,d=e"}},
+		{[]string{"env="}, map[string]string{"env": ""}},
+		{[]string{`a\\=b\`}, map[string]string{`a\`: `b\`}},
+		{[]string{`c:\dir=x\\,y=z`}, map[string]string{`c:\dir`: `x\`, "y": "z"}},
+	}
+
+	// Test valid values
+	for _, val := range data {
+		if err := arg.Set(val.input...); err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input %q", err, val.input)
+		}
+		if !reflect.DeepEqual(val.expected, testVar) {
+			t.Errorf("Expected: %v, Got: %v", val.expected, testVar)
+		}
+		// check that String() round trips through Set()
+		var roundTrip map[string]string
+		if err := NewStringMap(&roundTrip).Set(arg.String()); err != nil || !reflect.DeepEqual(roundTrip, testVar) {
+			t.Errorf("Expected: %v, Got: %v, %v for input %q", testVar, roundTrip, err, arg.String())
+		}
+	}
+
+	// Test invalid values
+	for _, input := range []string{"env", "a=b,c"} {
+		if err := arg.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+
+	var ints map[string]int
+	intArg := NewIntMap(&ints)
+	if err := intArg.Set("b=2,a=1"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s'", err)
+	}
+	if intArg.String() != "a=1,b=2" {
+		t.Errorf("Expected: a=1,b=2, Got: %v", intArg.String())
+	}
+	if err := intArg.Set("a=x"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"a=x\"")
+	}
+}
+
+func TestMapDuplicateKeys(t *testing.T) {
+	var testVar map[string]int
+	arg := NewIntMap(&testVar)
+
+	data := []struct {
+		policy   DuplicateKeyPolicy
+		expected int
+		fails    bool
+	}{
+		{DuplicateKeyOverwrite, 2, false},
+		{DuplicateKeyKeepFirst, 1, false},
+		{DuplicateKeyError, 0, true},
+	}
+	for _, val := range data {
+		arg.OnDuplicate = val.policy
+		err := arg.Set("a=1", "a=2")
+		if val.fails {
+			if err == nil {
+				t.Errorf("Expected: error for duplicate key with policy %v, Got: no error", val.policy)
+			}
+			continue
+		}
+		if err != nil || testVar["a"] != val.expected {
+			t.Errorf("Expected: a=%d with policy %v, Got: %v, %v", val.expected, val.policy, testVar, err)
+		}
+	}
+}
+
+func TestMapDuplicateKeysTag(t *testing.T) {
+	args := struct {
+		Label map[string]string `argparser:"onduplicate=error"`
+	}{}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := argset.ParseArgs([]string{"--label", "a=1", "--label", "a=2"}); err == nil {
+		t.Errorf("testing: ParseArgs() with duplicate key and onduplicate=error; expected: error; got: no error")
+	}
+
+	invalid := struct {
+		Name string `argparser:"onduplicate=keepfirst"`
+	}{}
+	if errs := CheckTags(invalid); len(errs) != 1 {
+		t.Errorf("testing: CheckTags() with onduplicate on a string field; expected: 1 error; got: %v", errs)
+	}
+}
+
+func TestRepeatedMapOption(t *testing.T) {
+	args := struct {
+		Label map[string]string `argparser:"help=labels"`
+	}{
+		Label: map[string]string{"default": "yes"},
+	}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := args.Label
+	argset.ArgList = []string{"--label", "env=prod", "--label", "team=core,tier=1"}
+	if err := argset.Parse(); err != nil {
+		t.Fatalf("testing: Parse(); expected: no error; got: %s", err)
+	}
+	expected := map[string]string{"env": "prod", "team": "core", "tier": "1"}
+	if !reflect.DeepEqual(expected, args.Label) {
+		t.Errorf("Expected: %v, Got: %v", expected, args.Label)
+	}
+	if len(previous) != 1 {
+		t.Errorf("Expected: previous map value left untouched, Got: %v", previous)
+	}
+}
+
+func TestRepeatedOptionNotAllowed(t *testing.T) {
+	var n int
+	argset := NewArgSet()
+	argset.Add("num", NewOptArg(NewInt(&n), ""))
+	argset.ArgList = []string{"--num", "1", "--num", "2"}
+	if err := argset.Parse(); err == nil {
+		t.Errorf("testing: Parse() with repeated --num; expected: error; got: no error")
+	}
+}
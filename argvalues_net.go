@@ -0,0 +1,403 @@
+package argparser
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	minPort int = 0
+	maxPort int = 65535
+)
+
+// IP type represents a net.IP value and implements Value interface
+type IP net.IP
+
+func NewIP(p *net.IP) *IP {
+	return (*IP)(p)
+}
+
+func parseIP(val string) (net.IP, error) {
+	ip := net.ParseIP(val)
+	if ip == nil {
+		return nil, formatParseError(val, fmt.Sprintf("%T", ip), errors.New("invalid IP address"))
+	}
+	return ip, nil
+}
+
+func (ip *IP) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseIP(values[0])
+	if err != nil {
+		return err
+	}
+	*ip = IP(v)
+	return nil
+}
+
+func (ip *IP) Get() interface{} { return net.IP(*ip) }
+
+// Metavar returns IP, the placeholder shown for the value in help
+func (ip *IP) Metavar() string { return "IP" }
+
+func (ip *IP) String() string {
+	if len(*ip) == 0 {
+		return ""
+	}
+	return net.IP(*ip).String()
+}
+
+// IPList type represents a list of net.IP values and implements Value interface
+type IPList []net.IP
+
+func NewIPList(p *[]net.IP) *IPList {
+	return (*IPList)(p)
+}
+
+func (il *IPList) Set(values ...string) error {
+	*il = make([]net.IP, len(values))
+	for i, val := range values {
+		v, err := parseIP(val)
+		if err != nil {
+			return err
+		}
+		(*il)[i] = v
+	}
+	return nil
+}
+
+func (il *IPList) Get() interface{} { return []net.IP(*il) }
+
+// Metavar returns IP, the placeholder shown for every element in help
+func (il *IPList) Metavar() string { return "IP" }
+
+func (il *IPList) String() string { return fmt.Sprint(*il) }
+
+// IPNet type represents a net.IPNet value given in CIDR notation and
+// implements Value interface
+type IPNet net.IPNet
+
+func NewIPNet(p *net.IPNet) *IPNet {
+	return (*IPNet)(p)
+}
+
+func parseCIDR(val string) (net.IPNet, error) {
+	_, n, err := net.ParseCIDR(val)
+	if err != nil {
+		return net.IPNet{}, formatParseError(val, fmt.Sprintf("%T", net.IPNet{}), errors.New("invalid CIDR address"))
+	}
+	return *n, nil
+}
+
+func (n *IPNet) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseCIDR(values[0])
+	if err != nil {
+		return err
+	}
+	*n = IPNet(v)
+	return nil
+}
+
+func (n *IPNet) Get() interface{} { return net.IPNet(*n) }
+
+// Metavar returns CIDR, hinting at the notation expected by Set
+func (n *IPNet) Metavar() string { return "CIDR" }
+
+func (n *IPNet) String() string {
+	if len(n.IP) == 0 {
+		return ""
+	}
+	return (*net.IPNet)(n).String()
+}
+
+// IPNetList type represents a list of net.IPNet values and implements Value interface
+type IPNetList []net.IPNet
+
+func NewIPNetList(p *[]net.IPNet) *IPNetList {
+	return (*IPNetList)(p)
+}
+
+func (nl *IPNetList) Set(values ...string) error {
+	*nl = make([]net.IPNet, len(values))
+	for i, val := range values {
+		v, err := parseCIDR(val)
+		if err != nil {
+			return err
+		}
+		(*nl)[i] = v
+	}
+	return nil
+}
+
+func (nl *IPNetList) Get() interface{} { return []net.IPNet(*nl) }
+
+// Metavar returns CIDR, the placeholder shown for every element in help
+func (nl *IPNetList) Metavar() string { return "CIDR" }
+
+func (nl *IPNetList) String() string {
+	s := make([]string, len(*nl))
+	for i := range *nl {
+		s[i] = (*IPNet)(&(*nl)[i]).String()
+	}
+	return fmt.Sprint(s)
+}
+
+// HardwareAddr type represents a net.HardwareAddr value and implements Value interface
+type HardwareAddr net.HardwareAddr
+
+func NewHardwareAddr(p *net.HardwareAddr) *HardwareAddr {
+	return (*HardwareAddr)(p)
+}
+
+func parseMAC(val string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(val)
+	if err != nil {
+		return nil, formatParseError(val, fmt.Sprintf("%T", mac), errors.New("invalid MAC address"))
+	}
+	return mac, nil
+}
+
+func (h *HardwareAddr) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseMAC(values[0])
+	if err != nil {
+		return err
+	}
+	*h = HardwareAddr(v)
+	return nil
+}
+
+func (h *HardwareAddr) Get() interface{} { return net.HardwareAddr(*h) }
+
+// Metavar returns MAC, the placeholder shown for the value in help
+func (h *HardwareAddr) Metavar() string { return "MAC" }
+
+func (h *HardwareAddr) String() string { return net.HardwareAddr(*h).String() }
+
+// HardwareAddrList type represents a list of net.HardwareAddr values and
+// implements Value interface
+type HardwareAddrList []net.HardwareAddr
+
+func NewHardwareAddrList(p *[]net.HardwareAddr) *HardwareAddrList {
+	return (*HardwareAddrList)(p)
+}
+
+func (hl *HardwareAddrList) Set(values ...string) error {
+	*hl = make([]net.HardwareAddr, len(values))
+	for i, val := range values {
+		v, err := parseMAC(val)
+		if err != nil {
+			return err
+		}
+		(*hl)[i] = v
+	}
+	return nil
+}
+
+func (hl *HardwareAddrList) Get() interface{} { return []net.HardwareAddr(*hl) }
+
+// Metavar returns MAC, the placeholder shown for every element in help
+func (hl *HardwareAddrList) Metavar() string { return "MAC" }
+
+func (hl *HardwareAddrList) String() string { return fmt.Sprint(*hl) }
+
+// HostPort represents a network address of the form host:port, where host may
+// be empty e.g. ':8080'. *HostPort implements Value interface and accepts only
+// numeric ports between 0 and 65535.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+func parseHostPort(val string) (HostPort, error) {
+	typeName := "host:port"
+	host, port, err := net.SplitHostPort(val)
+	if err != nil {
+		if ae, ok := err.(*net.AddrError); ok {
+			err = errors.New(ae.Err)
+		}
+		return HostPort{}, formatParseError(val, typeName, err)
+	}
+	if port == "" {
+		return HostPort{}, formatParseError(val, typeName, errors.New("missing port"))
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return HostPort{}, formatParseError(val, typeName, fmt.Errorf("invalid port '%s'", port))
+	}
+	if p < minPort || p > maxPort {
+		return HostPort{}, formatParseError(val, typeName, fmt.Errorf("port %d out of range [%d, %d]", p, minPort, maxPort))
+	}
+	return HostPort{Host: host, Port: p}, nil
+}
+
+func (hp *HostPort) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseHostPort(values[0])
+	if err != nil {
+		return err
+	}
+	*hp = v
+	return nil
+}
+
+func (hp *HostPort) Get() interface{} { return *hp }
+
+// Metavar returns HOST:PORT, showing the format expected by Set in help
+func (hp *HostPort) Metavar() string { return "HOST:PORT" }
+
+func (hp HostPort) String() string {
+	if hp.Host == "" && hp.Port == 0 {
+		return ""
+	}
+	return net.JoinHostPort(hp.Host, strconv.Itoa(hp.Port))
+}
+
+// HostPortList type represents a list of HostPort values and implements Value interface
+type HostPortList []HostPort
+
+func NewHostPortList(p *[]HostPort) *HostPortList {
+	return (*HostPortList)(p)
+}
+
+func (hl *HostPortList) Set(values ...string) error {
+	*hl = make([]HostPort, len(values))
+	for i, val := range values {
+		v, err := parseHostPort(val)
+		if err != nil {
+			return err
+		}
+		(*hl)[i] = v
+	}
+	return nil
+}
+
+func (hl *HostPortList) Get() interface{} { return []HostPort(*hl) }
+
+// Metavar returns HOST:PORT, the placeholder shown for every element in help
+func (hl *HostPortList) Metavar() string { return "HOST:PORT" }
+
+func (hl *HostPortList) String() string { return fmt.Sprint(*hl) }
+
+// URL type represents a *url.URL value and implements Value interface.
+// If Schemes is not empty then only URLs with one of the given schemes
+// are accepted.
+type URL struct {
+	dest    **url.URL
+	Schemes []string
+}
+
+func NewURL(p **url.URL, schemes ...string) *URL {
+	return &URL{dest: p, Schemes: schemes}
+}
+
+func parseURL(val string, schemes []string) (*url.URL, error) {
+	typeName := fmt.Sprintf("%T", &url.URL{})
+	u, err := url.Parse(val)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return nil, formatParseError(val, typeName, err)
+	}
+	if len(schemes) == 0 {
+		return u, nil
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return u, nil
+		}
+	}
+	return nil, formatParseError(val, typeName, fmt.Errorf("scheme must be one of: %s", strings.Join(schemes, ", ")))
+}
+
+func (u *URL) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseURL(values[0], u.Schemes)
+	if err != nil {
+		return err
+	}
+	*u.dest = v
+	return nil
+}
+
+func (u *URL) Get() interface{} { return *u.dest }
+
+// Metavar returns URL, the placeholder shown for the value in help
+func (u *URL) Metavar() string { return "URL" }
+
+// Clone returns a URL with the same schemes storing the current value in a new
+// variable. The url.URL itself is shared since Set never modifies it.
+func (u *URL) Clone() Value {
+	dest := new(*url.URL)
+	*dest = *u.dest
+	return &URL{dest: dest, Schemes: u.Schemes}
+}
+
+func (u *URL) String() string {
+	if *u.dest == nil {
+		return ""
+	}
+	return (*u.dest).String()
+}
+
+// URLList type represents a list of *url.URL values and implements Value interface.
+// If Schemes is not empty then only URLs with one of the given schemes
+// are accepted.
+type URLList struct {
+	dest    *[]*url.URL
+	Schemes []string
+}
+
+func NewURLList(p *[]*url.URL, schemes ...string) *URLList {
+	return &URLList{dest: p, Schemes: schemes}
+}
+
+func (ul *URLList) Set(values ...string) error {
+	list := make([]*url.URL, len(values))
+	for i, val := range values {
+		v, err := parseURL(val, ul.Schemes)
+		if err != nil {
+			return err
+		}
+		list[i] = v
+	}
+	*ul.dest = list
+	return nil
+}
+
+func (ul *URLList) Get() interface{} { return *ul.dest }
+
+// Metavar returns URL, the placeholder shown for every element in help
+func (ul *URLList) Metavar() string { return "URL" }
+
+// Clone returns a URLList with the same schemes storing a copy of the current
+// list in a new variable
+func (ul *URLList) Clone() Value {
+	dest := new([]*url.URL)
+	*dest = append([]*url.URL(nil), *ul.dest...)
+	return &URLList{dest: dest, Schemes: ul.Schemes}
+}
+
+func (ul *URLList) String() string {
+	s := make([]string, len(*ul.dest))
+	for i, u := range *ul.dest {
+		s[i] = u.String()
+	}
+	return fmt.Sprint(s)
+}
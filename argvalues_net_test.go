@@ -0,0 +1,167 @@
+package argparser
+
+import (
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestNetTypeValueCreation(t *testing.T) {
+	supported := []interface{}{
+		new(net.IP),
+		new([]net.IP),
+		new(net.IPNet),
+		new([]net.IPNet),
+		new(net.HardwareAddr),
+		new([]net.HardwareAddr),
+		new(HostPort),
+		new([]HostPort),
+		new(*url.URL),
+		new([]*url.URL),
+	}
+	for _, val := range supported {
+		if _, err := NewValue(val); err != nil {
+			t.Errorf("Expected: NewValue(%T) should succeed, Got: %s", val, err)
+		}
+	}
+}
+
+func TestIPType(t *testing.T) {
+	var testVar net.IP
+	arg := NewIP(&testVar)
+
+	for _, input := range []string{"192.168.1.1", "::1", "fe80::1"} {
+		if err := arg.Set(input); err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input \"%s\"", err, input)
+		}
+		if !testVar.Equal(net.ParseIP(input)) {
+			t.Errorf("Expected: %v, Got: %v", input, testVar)
+		}
+		if input != arg.String() {
+			t.Errorf("Expected: %v, Got: %v", input, arg.String())
+		}
+	}
+
+	// Test invalid values
+	for _, input := range []string{"hello", "1.1.1", "256.1.1.1", "10.0.0.0/8"} {
+		if err := arg.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+
+	var list []net.IP
+	listArg := NewIPList(&list)
+	if err := listArg.Set("10.0.0.1", "10.0.0.2"); err != nil || len(list) != 2 {
+		t.Errorf("Expected: 2 IPs and no error, Got: %v, %v", list, err)
+	}
+	if err := listArg.Set("10.0.0.1", "x"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"x\"")
+	}
+}
+
+func TestIPNetType(t *testing.T) {
+	var testVar net.IPNet
+	arg := NewIPNet(&testVar)
+
+	data := []struct {
+		input    string
+		expected string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"192.168.1.10/24", "192.168.1.0/24"},
+		{"2001:db8::/32", "2001:db8::/32"},
+	}
+	for _, val := range data {
+		if err := arg.Set(val.input); err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input \"%s\"", err, val.input)
+		}
+		if val.expected != arg.String() {
+			t.Errorf("Expected: %v, Got: %v", val.expected, arg.String())
+		}
+	}
+
+	for _, input := range []string{"10.0.0.0", "10.0.0.0/33", "hello/8"} {
+		if err := arg.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+}
+
+func TestHardwareAddrType(t *testing.T) {
+	var testVar net.HardwareAddr
+	arg := NewHardwareAddr(&testVar)
+	if err := arg.Set("00:00:5e:00:53:01"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s'", err)
+	}
+	if arg.String() != "00:00:5e:00:53:01" {
+		t.Errorf("Expected: 00:00:5e:00:53:01, Got: %v", arg.String())
+	}
+	if err := arg.Set("00:00:5e"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"00:00:5e\"")
+	}
+}
+
+func TestHostPortType(t *testing.T) {
+	var testVar HostPort
+
+	data := []struct {
+		input    string
+		expected HostPort
+	}{
+		{"localhost:8080", HostPort{"localhost", 8080}},
+		{":443", HostPort{"", 443}},
+		{"[::1]:0", HostPort{"::1", 0}},
+		{"10.0.0.1:65535", HostPort{"10.0.0.1", 65535}},
+	}
+	for _, val := range data {
+		if err := testVar.Set(val.input); err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input \"%s\"", err, val.input)
+		}
+		if val.expected != testVar {
+			t.Errorf("Expected: %v, Got: %v", val.expected, testVar)
+		}
+		if val.input != testVar.String() {
+			t.Errorf("Expected: %v, Got: %v", val.input, testVar.String())
+		}
+	}
+
+	for _, input := range []string{"localhost", "localhost:", "localhost:http", "host:65536", "host:-1", "::1:80"} {
+		if err := testVar.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+}
+
+func TestURLType(t *testing.T) {
+	var testVar *url.URL
+	arg := NewURL(&testVar)
+	if err := arg.Set("ftp://example.com/x"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s'", err)
+	}
+	if testVar == nil || testVar.Host != "example.com" || arg.String() != "ftp://example.com/x" {
+		t.Errorf("Expected: ftp://example.com/x, Got: %v", testVar)
+	}
+	if err := arg.Set("http://[::1"); err == nil {
+		t.Errorf("Expected: error, Got: no error for input \"http://[::1\"")
+	}
+
+	arg.Schemes = []string{"http", "https"}
+	if err := arg.Set("HTTPS://example.com"); err != nil {
+		t.Errorf("Expected: no error, Got: error '%s'", err)
+	}
+	if err := arg.Set("ftp://example.com"); err == nil {
+		t.Errorf("Expected: error since scheme is not allowed, Got: no error")
+	}
+
+	var list []*url.URL
+	listArg := NewURLList(&list, "https")
+	if err := listArg.Set("https://a", "https://b"); err != nil || len(list) != 2 {
+		t.Errorf("Expected: 2 URLs and no error, Got: %v, %v", list, err)
+	}
+	if listArg.String() != "[https://a https://b]" {
+		t.Errorf("Expected: [https://a https://b], Got: %v", listArg.String())
+	}
+	if err := listArg.Set("https://a", "http://b"); err == nil {
+		t.Errorf("Expected: error since scheme is not allowed, Got: no error")
+	}
+}
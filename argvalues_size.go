@@ -0,0 +1,181 @@
+package argparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type sizeUnit struct {
+	name  string
+	bytes int64
+}
+
+// iecUnits and siUnits are ordered from the largest to the smallest unit and
+// are used for formatting byte sizes
+var (
+	iecUnits = []sizeUnit{
+		{"EiB", 1 << 60}, {"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	}
+	siUnits = []sizeUnit{
+		{"EB", 1e18}, {"PB", 1e15}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	}
+)
+
+// sizeUnits maps lower cased unit suffixes to their size in bytes. Single
+// letter suffixes like 'K' or 'M' are treated as IEC units.
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "ki": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+	"m": 1 << 20, "mi": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+	"g": 1 << 30, "gi": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+	"t": 1 << 40, "ti": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+	"p": 1 << 50, "pi": 1 << 50, "pib": 1 << 50, "pb": 1e15,
+	"e": 1 << 60, "ei": 1 << 60, "eib": 1 << 60, "eb": 1e18,
+}
+
+func parseByteSize(val string) (int64, error) {
+	typeName := "byte size"
+	s := strings.TrimSpace(val)
+	if strings.HasPrefix(s, "-") {
+		return 0, formatParseError(val, typeName, errors.New("size cannot be negative"))
+	}
+	// split s into the numeric part and the unit suffix
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '+')
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, suffix := s[:i], strings.TrimSpace(s[i:])
+	if num == "" {
+		return 0, formatParseError(val, typeName, errors.New("missing number"))
+	}
+	unit, ok := sizeUnits[strings.ToLower(suffix)]
+	if !ok {
+		return 0, formatParseError(val, typeName, fmt.Errorf("unknown unit '%s'", suffix))
+	}
+
+	// use exact arithmetic since e.g. 4.1 cannot be represented as float64
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, formatParseError(val, typeName, errors.New("invalid number"))
+	}
+	r.Mul(r, new(big.Rat).SetInt64(unit))
+	if !r.IsInt() {
+		return 0, formatParseError(val, typeName, errors.New("not a whole number of bytes"))
+	}
+	if !r.Num().IsInt64() {
+		return 0, formatParseError(val, typeName, strconv.ErrRange)
+	}
+	return r.Num().Int64(), nil
+}
+
+func formatByteSize(n int64) string {
+	if n == 0 {
+		return "0B"
+	}
+	sign, abs := "", n
+	if n < 0 {
+		sign, abs = "-", -n
+	}
+	// use the largest exact unit from either system, preferring the one
+	// giving the smaller number e.g. 2TB rather than 1953125000KiB
+	best := sizeUnit{"B", 1}
+	for _, units := range [][]sizeUnit{iecUnits, siUnits} {
+		for _, u := range units {
+			if abs%u.bytes == 0 {
+				if u.bytes > best.bytes {
+					best = u
+				}
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%s%d%s", sign, abs/best.bytes, best.name)
+}
+
+// ByteSize type represents a size in bytes stored in an int64 and implements
+// Value interface. Sizes can be given with both SI (KB, MB, ...) and IEC
+// (KiB, MiB, ...) units where a single letter unit like 'K' is treated as IEC
+// e.g. '512K', '10MiB' or '1.5GB'. String() uses the largest unit which
+// represents the size exactly e.g. '64MiB' or '2TB'.
+type ByteSize int64
+
+func NewByteSize(p *int64) *ByteSize {
+	return (*ByteSize)(p)
+}
+
+func (b *ByteSize) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseByteSize(values[0])
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(v)
+	return nil
+}
+
+func (b *ByteSize) Get() interface{} { return int64(*b) }
+
+// Metavar returns SIZE, the placeholder shown for the value in help
+func (b *ByteSize) Metavar() string { return "SIZE" }
+
+func (b ByteSize) String() string { return formatByteSize(int64(b)) }
+
+// Ratio type represents a ratio stored in a float64 and implements Value
+// interface. It can be given either as a percentage like '75%' or as a plain
+// number like '0.75', both of which are stored as 0.75. String() always
+// uses the percentage form.
+type Ratio float64
+
+func NewRatio(p *float64) *Ratio {
+	return (*Ratio)(p)
+}
+
+func parseRatio(val string) (float64, error) {
+	typeName := "ratio"
+	s := strings.TrimSpace(val)
+	percent := strings.HasSuffix(s, "%")
+	if percent {
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, formatParseError(val, typeName, err)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, formatParseError(val, typeName, errors.New("not a finite number"))
+	}
+	if percent {
+		f /= 100
+	}
+	return f, nil
+}
+
+func (r *Ratio) Set(values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	v, err := parseRatio(values[0])
+	if err != nil {
+		return err
+	}
+	*r = Ratio(v)
+	return nil
+}
+
+func (r *Ratio) Get() interface{} { return float64(*r) }
+
+// Metavar returns RATIO, the placeholder shown for the value in help
+func (r *Ratio) Metavar() string { return "RATIO" }
+
+func (r Ratio) String() string {
+	// 10 significant digits hide the rounding error from multiplying by 100
+	return strconv.FormatFloat(float64(r)*100, 'g', 10, 64) + "%"
+}
@@ -0,0 +1,113 @@
+package argparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestByteSizeType(t *testing.T) {
+	var testVar int64
+	arg := NewByteSize(&testVar)
+
+	data := []struct {
+		input    string
+		expected int64
+		str      string
+	}{
+		{"0", 0, "0B"},
+		{"100", 100, "100B"},
+		{"100B", 100, "100B"},
+		{"512K", 512 << 10, "512KiB"},
+		{"512k", 512 << 10, "512KiB"},
+		{"10MiB", 10 << 20, "10MiB"},
+		{"64mib", 64 << 20, "64MiB"},
+		{"1.5GB", 1500000000, "1500MB"},
+		{"1.5GiB", 3 << 29, "1536MiB"},
+		{"2TB", 2e12, "2TB"},
+		{"1000", 1000, "1KB"},
+		{"1 KiB", 1024, "1KiB"},
+		{"4.1GB", 4100000000, "4100MB"},
+		{"1.5KiB", 1536, "1536B"},
+		{"0.001KB", 1, "1B"},
+		{"8EiB", 0, ""},
+	}
+
+	for _, val := range data {
+		err := arg.Set(val.input)
+		if val.str == "" {
+			if err == nil {
+				t.Errorf("Expected: overflow error, Got: no error for input \"%s\"", val.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input \"%s\"", err, val.input)
+		}
+		if val.expected != testVar {
+			t.Errorf("Expected: %v, Got: %v for input \"%s\"", val.expected, testVar, val.input)
+		}
+		if val.str != arg.String() {
+			t.Errorf("Expected: %v, Got: %v", val.str, arg.String())
+		}
+	}
+
+	// Test invalid values
+	for _, input := range []string{"", "hello", "10XB", "-1K", "1.0001B", "K", "1..5M"} {
+		if err := arg.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+}
+
+func TestRatioType(t *testing.T) {
+	var testVar float64
+	arg := NewRatio(&testVar)
+
+	data := []struct {
+		input    string
+		expected float64
+		str      string
+	}{
+		{"75%", 0.75, "75%"},
+		{"0.75", 0.75, "75%"},
+		{"7%", 0.07, "7%"},
+		{"12.5 %", 0.125, "12.5%"},
+		{"1", 1, "100%"},
+		{"0", 0, "0%"},
+	}
+	for _, val := range data {
+		if err := arg.Set(val.input); err != nil {
+			t.Errorf("Expected: no error, Got: error '%s' for input \"%s\"", err, val.input)
+		}
+		if val.expected != testVar {
+			t.Errorf("Expected: %v, Got: %v", val.expected, testVar)
+		}
+		if val.str != arg.String() {
+			t.Errorf("Expected: %v, Got: %v", val.str, arg.String())
+		}
+	}
+
+	for _, input := range []string{"", "%", "abc%", "NaN", "Inf%"} {
+		if err := arg.Set(input); err == nil {
+			t.Errorf("Expected: error, Got: no error for input \"%s\"", input)
+		}
+	}
+}
+
+func TestByteSizeDefaultInUsage(t *testing.T) {
+	args := struct {
+		Cache ByteSize `argparser:"help=cache size"`
+	}{
+		Cache: 64 << 20,
+	}
+	argset, err := NewArgSetFrom(&args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+	argset.usage()
+	if !strings.Contains(out.String(), "64MiB") {
+		t.Errorf("testing: usage(); expected: default shown as 64MiB; got: %s", out.String())
+	}
+}
@@ -0,0 +1,89 @@
+package argparser
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+const versionArgName string = "version"
+
+// addVersion adds the --version switch if a version has been configured and
+// no optional argument named 'version' exists yet. It is called by every method
+// listing the arguments of argSet, not just by Parse, so that the switch is
+// seen as soon as Version is set.
+func (argSet *ArgSet) addVersion() {
+	if argSet.Version == "" && !argSet.VersionBuildInfo {
+		return
+	}
+	if _, found := argSet.optArgs[argSet.OptArgPrefix+versionArgName]; found {
+		return
+	}
+	var version bool
+	versionArg := NewSwitchArg(NewBool(&version), "Show version information and exit")
+	versionArg.SetAction(func(set *ArgSet, _ interface{}) error {
+		set.println(set.VersionString())
+		return ErrStopParsing
+	})
+	versionArg.builtin = true
+	argSet.add(versionArgName, versionArg)
+}
+
+// removeVersion removes the --version switch added by addVersion if any of
+// names is 'version', so that the user can add an argument of that name
+func (argSet *ArgSet) removeVersion(names []string) {
+	versionArg := argSet.optArgs[argSet.OptArgPrefix+versionArgName]
+	if versionArg == nil || !versionArg.builtin {
+		return
+	}
+	for _, name := range names {
+		if name != versionArgName {
+			continue
+		}
+		delete(argSet.optArgs, argSet.OptArgPrefix+versionArgName)
+		for i, arg := range argSet.args {
+			if arg == versionArg {
+				argSet.args = append(argSet.args[:i:i], argSet.args[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+}
+
+// VersionString returns the text printed by --version: the program name
+// followed by Version and, if VersionBuildInfo is true, details from the
+// build info of the running binary. If Version is empty the module version
+// from the build info is used instead.
+func (argSet *ArgSet) VersionString() string {
+	version := argSet.Version
+	var details []string
+	if argSet.VersionBuildInfo {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			if version == "" {
+				version = bi.Main.Version
+			}
+			if bi.Main.Path != "" {
+				details = append(details, fmt.Sprintf("module: %s %s", bi.Main.Path, bi.Main.Version))
+			}
+			if revision, modified := vcsInfo(bi); revision != "" {
+				if modified {
+					revision += " (dirty)"
+				}
+				details = append(details, "revision: "+revision)
+			}
+		}
+		details = append(details, "go: "+runtime.Version())
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(argSet.name)
+	if version != "" {
+		b.WriteString(" " + version)
+	}
+	for _, d := range details {
+		b.WriteString("\n  " + d)
+	}
+	return b.String()
+}
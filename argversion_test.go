@@ -0,0 +1,77 @@
+package argparser
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	var opt int
+	argset := NewArgSet()
+	argset.name = "prog"
+	argset.Add("opt", NewOptArg(NewInt(&opt), ""))
+	out := &strings.Builder{}
+	argset.SetOutput(out)
+
+	// Test that --version is unknown unless a version is configured
+	argset.ArgList = []string{"--version"}
+	if err := argset.Parse(); err == nil {
+		t.Errorf("testing: Parse(%q) without Version; expected: error; got: no error", argset.ArgList)
+	}
+
+	argset.Version = "1.2.3"
+	argset.ArgList = []string{"--version", "--opt", "1"}
+	if err := argset.Parse(); err != nil || opt != 0 {
+		t.Errorf("testing: Parse(%q); expected: no error and --opt not processed; got: %d, %v", argset.ArgList, opt, err)
+	}
+	if out.String() != "prog 1.2.3\n" {
+		t.Errorf("testing: Parse(%q); expected: %q; got: %q", argset.ArgList, "prog 1.2.3\n", out.String())
+	}
+
+	// Test that build info is added when requested
+	out.Reset()
+	argset.VersionBuildInfo = true
+	argset.Parse()
+	if !strings.HasPrefix(out.String(), "prog 1.2.3\n") || !strings.Contains(out.String(), "go: "+runtime.Version()) {
+		t.Errorf("testing: Parse(%q) with VersionBuildInfo; expected: version and Go version; got: %q", argset.ArgList, out.String())
+	}
+}
+
+func TestVersionDoesNotReplaceExistingArgument(t *testing.T) {
+	var version int
+	argset := NewArgSet()
+	argset.Add("version", NewOptArg(NewInt(&version), ""))
+	argset.Version = "1.2.3"
+	argset.ArgList = []string{"--version", "2"}
+	if err := argset.Parse(); err != nil || version != 2 {
+		t.Errorf("testing: Parse(%q); expected: user defined --version set to 2; got: %d, %v", argset.ArgList, version, err)
+	}
+}
+
+func TestVersionBeforeParse(t *testing.T) {
+	argset := NewArgSet()
+	argset.Version = "1.2.3"
+	if argset.Lookup("version") == nil {
+		t.Errorf("testing: Lookup(version) before Parse; expected: --version; got: nil")
+	}
+	found := false
+	for _, arg := range argset.Arguments() {
+		found = found || arg.Name() == "version"
+	}
+	if !found {
+		t.Errorf("testing: Arguments() before Parse; expected: --version listed")
+	}
+	if !strings.Contains(argset.HelpModel(false).Sections[1].Args[1].Synopsis, "--version") {
+		t.Errorf("testing: HelpModel() before Parse; expected: --version listed; got: %+v", argset.HelpModel(false).Sections[1])
+	}
+
+	// Test that an argument added later still replaces the built-in switch
+	var version string
+	if err := argset.Add("version", NewOptArg(NewString(&version), "")); err != nil {
+		t.Fatalf("testing: Add(version) after Lookup; expected: no error; got: %v", err)
+	}
+	if err := argset.ParseArgs([]string{"--version", "2"}); err != nil || version != "2" || len(argset.Arguments()) != 2 {
+		t.Errorf("testing: ParseArgs(--version 2); expected: user defined --version set to 2; got: %q, %v", version, err)
+	}
+}
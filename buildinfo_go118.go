@@ -0,0 +1,19 @@
+//go:build go1.18
+// +build go1.18
+
+package argparser
+
+import "runtime/debug"
+
+// vcsInfo returns the VCS revision and modified flag recorded in bi
+func vcsInfo(bi *debug.BuildInfo) (revision string, modified bool) {
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	return revision, modified
+}
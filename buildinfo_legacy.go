@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package argparser
+
+import "runtime/debug"
+
+// vcsInfo returns nothing since VCS details are only recorded in the build
+// info by Go 1.18 and later
+func vcsInfo(bi *debug.BuildInfo) (revision string, modified bool) {
+	return "", false
+}
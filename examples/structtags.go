@@ -25,12 +25,10 @@ func main() {
 	}
 	mainSet.Description = "CLI for managing employee database"
 
-	mainSet.ArgList = []string{"--help"}
-	mainSet.Parse()
+	mainSet.ParseArgs([]string{"--help"})
 
 	fmt.Printf("\nBEFORE parsing: %+v\n", config)
-	mainSet.ArgList = []string{"3.4", "asd", "--salute", "XXX", "--is-intern", "--emp-id", "88888", "345", "33"}
-	err = mainSet.Parse()
+	err = mainSet.ParseArgs([]string{"3.4", "asd", "--salute", "XXX", "--is-intern", "--emp-id", "88888", "345", "33"})
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -3,6 +3,8 @@ package argparser
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 func formatParseError(val string, typeName string, err error) error {
@@ -14,3 +16,54 @@ func formatParseError(val string, typeName string, err error) error {
 	}
 	return fmt.Errorf("cannot parse '%s' as type '%s': %s", val, typeName, reason)
 }
+
+// defaultHelpWidth is the width help is wrapped to unless ArgSet.HelpWidth is set
+const defaultHelpWidth = 80
+
+// wrapText wraps text into lines of at most width runes. Paragraphs are
+// separated by blank lines and are kept apart by a single empty line. Lines
+// starting with white space are considered preformatted, e.g. examples, and
+// are kept as they are. Words longer than width are not broken.
+func wrapText(text string, width int) []string {
+	lines := make([]string, 0)
+	words := make([]string, 0)
+	flush := func() {
+		line := ""
+		for _, w := range words {
+			if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(w) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += w
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		words = words[:0]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case line == "":
+			flush()
+			// keep a single empty line between paragraphs
+			if len(lines) > 0 && lines[len(lines)-1] != "" {
+				lines = append(lines, "")
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			flush()
+			lines = append(lines, line)
+		default:
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	flush()
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
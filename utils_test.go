@@ -0,0 +1,28 @@
+package argparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	data := []struct {
+		text     string
+		width    int
+		expected []string
+	}{
+		{"", 10, []string{}},
+		{"short", 10, []string{"short"}},
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"a\nsingle\nparagraph", 20, []string{"a single paragraph"}},
+		{"first para\n\n\n\nsecond para\n\n", 20, []string{"first para", "", "second para"}},
+		{"example:\n  $ prog --x 1\n\tmore", 8, []string{"example:", "  $ prog --x 1", "\tmore"}},
+		{"unbreakablewords here", 5, []string{"unbreakablewords", "here"}},
+		{"ünïcödé wörds", 7, []string{"ünïcödé", "wörds"}},
+	}
+	for _, d := range data {
+		if got := wrapText(d.text, d.width); !reflect.DeepEqual(got, d.expected) {
+			t.Errorf("testing: wrapText(%q, %d); expected: %q; got: %q", d.text, d.width, d.expected, got)
+		}
+	}
+}